@@ -0,0 +1,95 @@
+package watchdog
+
+import (
+	"context"
+	"time"
+)
+
+// Outcome of a bounded run of a Watchdog (see RunFor)
+type Summary struct {
+	Executions int
+	// Executions which returned an error
+	Failures     int
+	Stalls       int
+	Degradations int
+	// Highest Severity of any event received
+	Severity Severity
+}
+
+// Process exit code for the run: 2 if any execution failed or
+// stalled, or any event was Critical; 1 if there were other warnings
+// (e.g. slow executions or gates timing out); 0 otherwise.
+func (s Summary) ExitCode() int {
+	if s.Failures > 0 || s.Stalls > 0 || s.Severity >= Critical {
+		return 2
+	}
+	if s.Severity >= Warning {
+		return 1
+	}
+	return 0
+}
+
+// Run the Watchdog for the given duration, or until the context is
+// done or the Watchdog is stopped by other means, then Stop it and
+// summarize what happened: for batch use in CI or cron jobs, e.g.
+//
+//	w := watchdog.Watch(tasks...)
+//	os.Exit(w.RunFor(ctx, 5*time.Minute).ExitCode())
+//
+// RunFor drains the Executions, Stalls and Degradations channels
+// itself, so they must not be read from elsewhere while it runs. The
+// duration is measured on the Watchdog's clock: under Simulate, it
+// only passes as Advance is called from another goroutine.
+func (w *Watchdog) RunFor(ctx context.Context, d time.Duration) Summary {
+	stopped := make(chan bool)
+	// Created up front, so that simulated time advanced while
+	// RunFor runs all counts
+	deadline := w.clock.newTimer(d)
+	go func() {
+		defer deadline.stop()
+		select {
+		case <-ctx.Done():
+		case <-deadline.channel():
+		case <-w.done:
+		}
+		w.Stop()
+		close(stopped)
+	}()
+	var s Summary
+	observe := func(severity Severity) {
+		if severity > s.Severity {
+			s.Severity = severity
+		}
+	}
+	executions, stalls, degradations := w.executions, w.stalls, w.degradations
+	for executions != nil || stalls != nil || degradations != nil {
+		select {
+		case e, ok := <-executions:
+			if !ok {
+				executions = nil
+				continue
+			}
+			s.Executions += 1
+			if e.Error != nil {
+				s.Failures += 1
+			}
+			observe(e.Severity)
+		case stall, ok := <-stalls:
+			if !ok {
+				stalls = nil
+				continue
+			}
+			s.Stalls += 1
+			observe(stall.Severity)
+		case d, ok := <-degradations:
+			if !ok {
+				degradations = nil
+				continue
+			}
+			s.Degradations += 1
+			observe(d.Severity)
+		}
+	}
+	<-stopped
+	return s
+}
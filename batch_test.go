@@ -0,0 +1,76 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSummaryExitCode(t *testing.T) {
+	for i, tc := range []struct {
+		summary  Summary
+		expected int
+	}{
+		{Summary{Executions: 3}, 0},
+		{Summary{Executions: 3, Severity: Warning}, 1},
+		{Summary{Executions: 3, Degradations: 1, Severity: Warning}, 1},
+		{Summary{Executions: 3, Failures: 1, Severity: Warning}, 2},
+		{Summary{Executions: 3, Stalls: 1, Severity: Warning}, 2},
+		{Summary{Executions: 3, Severity: Critical}, 2},
+	} {
+		if code := tc.summary.ExitCode(); code != tc.expected {
+			t.Errorf("case %d: expected exit code %d; got %d", i, tc.expected, code)
+		}
+	}
+}
+
+func TestRunFor(t *testing.T) {
+	calls := 0
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command: func(time.Time) error {
+			calls += 1
+			if calls == 2 {
+				return errors.New("oh snap")
+			}
+			return nil
+		},
+		Timeout: 1 * time.Second,
+	})
+	s := w.RunFor(context.Background(), 55*time.Millisecond)
+	if s.Executions < 3 || s.Failures != 1 || s.Stalls != 0 {
+		t.Errorf("expected at least 3 executions with 1 failure; got %+v", s)
+	}
+	if s.Severity != Warning || s.ExitCode() != 2 {
+		t.Errorf("expected a failed run; got severity %v and exit code %d", s.Severity, s.ExitCode())
+	}
+	if _, ok := <-w.Executions(); ok {
+		t.Errorf("expected Watchdog to be stopped after RunFor returns")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s := Watch(newTestTask("")).RunFor(ctx, 1*time.Hour); s.ExitCode() != 0 {
+		t.Errorf("expected a clean run when cancelled at once; got %+v", s)
+	}
+}
+
+func TestRunForSimulated(t *testing.T) {
+	w := Simulate(simStart, newTestTask(""))
+	summaries := make(chan Summary)
+	go func() {
+		summaries <- w.RunFor(context.Background(), 1*time.Hour)
+	}()
+	select {
+	case s := <-summaries:
+		t.Fatalf("expected the run to last an hour of simulated time; got %+v", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+	w.Advance(1 * time.Hour)
+	select {
+	case <-summaries:
+	case <-time.After(1 * time.Second):
+		t.Fatalf("expected the run to end once an hour was simulated")
+	}
+}
@@ -0,0 +1,21 @@
+package watchdog
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// CLOCK_BOOTTIME: like CLOCK_MONOTONIC, but includes time suspended
+const clockBoottime = 7
+
+// Time since boot, including time spent suspended
+func bootTime() (time.Duration, bool) {
+	var ts syscall.Timespec
+	_, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME,
+		clockBoottime, uintptr(unsafe.Pointer(&ts)), 0)
+	if errno != 0 {
+		return 0, false
+	}
+	return time.Duration(ts.Nano()), true
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package watchdog
+
+import (
+	"time"
+)
+
+// Time since boot, including time spent suspended; unsupported here
+func bootTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,237 @@
+package watchdog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// A set of times, such as business hours, that a Task can be
+// restricted to (see Task.Calendar)
+type Calendar interface {
+	// Whether the calendar includes the given time
+	Contains(t time.Time) bool
+}
+
+// Working hours on working days, excluding holidays
+type BusinessHours struct {
+	// Daily opening and closing times, as offsets from midnight
+	Open  time.Duration
+	Close time.Duration
+	// Working days; Monday to Friday if empty
+	Days []time.Weekday
+	// Time zone the hours are given in; local time if nil
+	Location *time.Location
+	// Optional days excluded from working days
+	Holidays Calendar
+}
+
+func (b BusinessHours) Contains(t time.Time) bool {
+	loc := b.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	if b.Holidays != nil && b.Holidays.Contains(t) {
+		return false
+	}
+	workday := false
+	if len(b.Days) == 0 {
+		workday = t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+	}
+	for _, day := range b.Days {
+		if t.Weekday() == day {
+			workday = true
+		}
+	}
+	if !workday {
+		return false
+	}
+	// Wall-clock time of day, which elapsed time since midnight is
+	// not on days the clocks change
+	hour, minute, sec := t.Clock()
+	sinceMidnight := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute +
+		time.Duration(sec)*time.Second + time.Duration(t.Nanosecond())
+	return sinceMidnight >= b.Open && sinceMidnight < b.Close
+}
+
+// Whole days, such as public holidays, keyed by date in 2006-01-02
+// format. A time is contained if its date (in its own location) is.
+type Holidays map[string]bool
+
+func (h Holidays) Contains(t time.Time) bool {
+	return h[t.Format("2006-01-02")]
+}
+
+// Calendar containing exactly the times the given one does not, e.g.
+// to run only outside a maintenance window
+func Outside(c Calendar) Calendar {
+	return outside{c}
+}
+
+type outside struct {
+	Calendar
+}
+
+func (o outside) Contains(t time.Time) bool {
+	return !o.Calendar.Contains(t)
+}
+
+// A set of times a Task may execute in (see Task.Window): unlike a
+// Calendar, executions falling outside it are deferred rather than
+// skipped
+type Window interface {
+	Calendar
+	// Earliest time at or after t that the window contains
+	Next(t time.Time) time.Time
+}
+
+// Information about an execution deferred by its Task's Window for
+// longer than the Task's MaxDeferral, reported while it still waits
+type Deferral struct {
+	// Task whose execution is deferred
+	Task *Task
+	// Time the execution was scheduled for
+	StartedAt time.Time
+	// Time the execution started waiting for the Window
+	Since time.Time
+	// Time the wait exceeded MaxDeferral
+	ExceededAt time.Time
+	// Critical for critical Tasks, Warning otherwise
+	Severity Severity
+}
+
+// Channel of executions deferred for longer than their Task's
+// MaxDeferral, sent as soon as the deferral exceeds it rather than
+// once the execution finally runs, which may be up to a full Window
+// period later (or never, if the Watchdog stops first). Like
+// ClockJumps, this channel need not be drained.
+func (w *Watchdog) Deferrals() <-chan *Deferral {
+	return w.deferrals
+}
+
+func (w *Watchdog) sendDeferral(d *Deferral) {
+	select {
+	case w.deferrals <- d:
+	default:
+		atomic.AddUint64(&w.droppedDeferrals, 1)
+	}
+}
+
+// Window open for the same span of every period, e.g. minutes 0 to 5
+// of each hour. Periods are aligned as by time.Time.Truncate.
+type Periodic struct {
+	Period time.Duration
+	// Opening and closing times, as offsets from the start of each
+	// period
+	Open  time.Duration
+	Close time.Duration
+}
+
+// Check the window opens at all: within each Period, Open must come
+// before Close
+func (p Periodic) validate() error {
+	if p.Period <= 0 {
+		return fmt.Errorf("periodic window period %v is not positive", p.Period)
+	}
+	if p.Open < 0 || p.Open >= p.Close || p.Close > p.Period {
+		return fmt.Errorf("periodic window from %v to %v does not fit within %v",
+			p.Open, p.Close, p.Period)
+	}
+	return nil
+}
+
+func (p Periodic) Contains(t time.Time) bool {
+	offset := t.Sub(t.Truncate(p.Period))
+	return offset >= p.Open && offset < p.Close
+}
+
+func (p Periodic) Next(t time.Time) time.Time {
+	if p.Contains(t) {
+		return t
+	}
+	start := t.Truncate(p.Period)
+	if t.Sub(start) < p.Open {
+		return start.Add(p.Open)
+	}
+	return start.Add(p.Period + p.Open)
+}
+
+// Read the days covered by the events of an iCalendar (ICS) file,
+// such as a published holiday calendar. Only the date part of each
+// event's DTSTART and DTEND is used; DTEND is exclusive, and an event
+// without one covers a single day. Events spanning more than a year
+// are rejected.
+func ParseICS(r io.Reader) (Holidays, error) {
+	holidays := make(Holidays)
+	var start, end time.Time
+	inEvent := false
+	scanner := bufio.NewScanner(r)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Unfold continuation lines (RFC 5545 section 3.1)
+		if n := len(lines); n > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[n-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Drop parameters such as ;VALUE=DATE
+		name, _, _ = strings.Cut(name, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if value == "VEVENT" {
+				inEvent = true
+				start, end = time.Time{}, time.Time{}
+			}
+		case "DTSTART", "DTEND":
+			if !inEvent {
+				continue
+			}
+			if len(value) < 8 {
+				return nil, fmt.Errorf("watchdog: invalid ICS date %q", value)
+			}
+			date, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return nil, fmt.Errorf("watchdog: invalid ICS date %q", value)
+			}
+			if strings.ToUpper(name) == "DTSTART" {
+				start = date
+			} else {
+				end = date
+			}
+		case "END":
+			if value != "VEVENT" || !inEvent {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				return nil, fmt.Errorf("watchdog: ICS event without DTSTART")
+			}
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			// Not a holiday, and costly to expand day by day
+			if end.After(start.AddDate(1, 0, 0)) {
+				return nil, fmt.Errorf("watchdog: ICS event from %s spans more than a year",
+					start.Format("2006-01-02"))
+			}
+			for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+				holidays[day.Format("2006-01-02")] = true
+			}
+		}
+	}
+	return holidays, nil
+}
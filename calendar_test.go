@@ -0,0 +1,299 @@
+package watchdog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const holidaysICS = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Christmas Day\r\n" +
+	"DTSTART;VALUE=DATE:20141225\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Company retreat, spanning a\r\n" +
+	" folded line\r\n" +
+	"DTSTART;VALUE=DATE:20141229\r\n" +
+	"DTEND;VALUE=DATE:20141231\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	holidays, err := ParseICS(strings.NewReader(holidaysICS))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, date := range []string{"2014-12-25", "2014-12-29", "2014-12-30"} {
+		if !holidays[date] {
+			t.Errorf("expected %s to be a holiday", date)
+		}
+	}
+	if len(holidays) != 3 {
+		t.Errorf("expected 3 holidays; got %v", holidays)
+	}
+
+	_, err = ParseICS(strings.NewReader("BEGIN:VEVENT\nDTSTART:2014\nEND:VEVENT\n"))
+	if err == nil {
+		t.Errorf("expected an error for an invalid date")
+	}
+	_, err = ParseICS(strings.NewReader("BEGIN:VEVENT\nDTSTART:00010101\nDTEND:99991231\nEND:VEVENT\n"))
+	if err == nil {
+		t.Errorf("expected an error for an event spanning millennia")
+	}
+}
+
+func FuzzParseICS(f *testing.F) {
+	f.Add(holidaysICS)
+	f.Add("BEGIN:VEVENT\nDTSTART:2014\nEND:VEVENT\n")
+	f.Add("BEGIN:VEVENT\nDTSTART:20141225\nDTEND:20141224\nEND:VEVENT\n")
+	f.Fuzz(func(t *testing.T, ics string) {
+		holidays, err := ParseICS(strings.NewReader(ics))
+		if err != nil {
+			return
+		}
+		for date := range holidays {
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				t.Errorf("expected holidays keyed by date; got %q", date)
+			}
+		}
+	})
+}
+
+func TestBusinessHours(t *testing.T) {
+	hours := BusinessHours{
+		Open:     9 * time.Hour,
+		Close:    17*time.Hour + 30*time.Minute,
+		Location: time.UTC,
+		Holidays: Holidays{"2014-12-25": true},
+	}
+	for _, tc := range []struct {
+		at       time.Time
+		expected bool
+	}{
+		// Wednesday
+		{time.Date(2014, 12, 24, 12, 0, 0, 0, time.UTC), true},
+		{time.Date(2014, 12, 24, 9, 0, 0, 0, time.UTC), true},
+		{time.Date(2014, 12, 24, 8, 59, 0, 0, time.UTC), false},
+		{time.Date(2014, 12, 24, 17, 30, 0, 0, time.UTC), false},
+		// Same instant, expressed in another zone
+		{time.Date(2014, 12, 24, 7, 0, 0, 0, time.FixedZone("UTC-5", -5*3600)), true},
+		// Holiday
+		{time.Date(2014, 12, 25, 12, 0, 0, 0, time.UTC), false},
+		// Saturday
+		{time.Date(2014, 12, 27, 12, 0, 0, 0, time.UTC), false},
+	} {
+		if contained := hours.Contains(tc.at); contained != tc.expected {
+			t.Errorf("%v: expected business hours to contain it: %v; got %v",
+				tc.at, tc.expected, contained)
+		}
+	}
+	weekends := BusinessHours{Close: 24 * time.Hour, Days: []time.Weekday{time.Saturday}}
+	if !weekends.Contains(time.Date(2014, 12, 27, 3, 0, 0, 0, time.Local)) {
+		t.Errorf("expected custom working days to be honoured")
+	}
+	if Outside(hours).Contains(time.Date(2014, 12, 24, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected Outside to invert the calendar")
+	}
+}
+
+func TestBusinessHoursDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	// Clocks went forward an hour at 2am on Sunday, 9 March 2014
+	hours := BusinessHours{
+		Open:     9*time.Hour + 30*time.Minute,
+		Close:    17 * time.Hour,
+		Days:     []time.Weekday{time.Sunday},
+		Location: newYork,
+	}
+	for _, tc := range []struct {
+		at       time.Time
+		expected bool
+	}{
+		{time.Date(2014, 3, 9, 9, 45, 0, 0, newYork), true},
+		{time.Date(2014, 3, 9, 9, 15, 0, 0, newYork), false},
+		{time.Date(2014, 3, 9, 16, 45, 0, 0, newYork), true},
+	} {
+		if contained := hours.Contains(tc.at); contained != tc.expected {
+			t.Errorf("%v: expected business hours to contain it: %v; got %v",
+				tc.at, tc.expected, contained)
+		}
+	}
+}
+
+func TestTaskCalendar(t *testing.T) {
+	// Simulated time starts at noon: allow only the first two
+	// seconds of every minute
+	window := calendarFunc(func(at time.Time) bool { return at.Second() < 2 })
+	runs := make(chan time.Time, 10)
+	w := Simulate(simStart, &Task{
+		Schedule: 1 * time.Second,
+		Command: func(at time.Time) error {
+			runs <- at
+			return nil
+		},
+		Timeout:  1 * time.Second,
+		Calendar: window,
+	})
+	discard(w)
+	for i := 0; i < 5; i++ {
+		w.Advance(1 * time.Second)
+		// Give any execution a chance to run before the next tick
+		<-time.After(5 * time.Millisecond)
+	}
+	w.Stop()
+	close(runs)
+	count := 0
+	for at := range runs {
+		count += 1
+		if at.Second() >= 2 {
+			t.Errorf("expected no executions outside the calendar; ran at %v", at)
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected 1 execution within the calendar; got %d", count)
+	}
+}
+
+func TestPeriodic(t *testing.T) {
+	window := Periodic{Period: 1 * time.Hour, Open: 0, Close: 5 * time.Minute}
+	hour := time.Date(2014, 12, 24, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		at       time.Time
+		contains bool
+		next     time.Time
+	}{
+		{hour, true, hour},
+		{hour.Add(4 * time.Minute), true, hour.Add(4 * time.Minute)},
+		{hour.Add(5 * time.Minute), false, hour.Add(1 * time.Hour)},
+		{hour.Add(-1 * time.Minute), false, hour},
+	} {
+		if contained := window.Contains(tc.at); contained != tc.contains {
+			t.Errorf("%v: expected window to contain it: %v; got %v", tc.at, tc.contains, contained)
+		}
+		if next := window.Next(tc.at); !next.Equal(tc.next) {
+			t.Errorf("%v: expected next opening at %v; got %v", tc.at, tc.next, next)
+		}
+	}
+	late := Periodic{Period: 1 * time.Hour, Open: 30 * time.Minute, Close: 35 * time.Minute}
+	if next := late.Next(hour.Add(10 * time.Minute)); !next.Equal(hour.Add(30 * time.Minute)) {
+		t.Errorf("expected the window to open later in the same period; got %v", next)
+	}
+}
+
+func TestTaskWindow(t *testing.T) {
+	opensAt := time.Now().Add(100 * time.Millisecond)
+	w := Watch(&Task{
+		Schedule:    10 * time.Millisecond,
+		Command:     func(time.Time) error { return nil },
+		Timeout:     1 * time.Second,
+		Window:      openingAt(opensAt),
+		MaxDeferral: 50 * time.Millisecond,
+	})
+	exec := <-w.Executions()
+	discard(w)
+	w.Stop()
+	if exec.DispatchedAt.Before(opensAt) {
+		t.Errorf("expected no executions before the window opened at %v; dispatched at %v",
+			opensAt, exec.DispatchedAt)
+	}
+	if exec.Deferred < 50*time.Millisecond {
+		t.Errorf("expected the execution to be deferred; deferred %v", exec.Deferred)
+	}
+	if exec.Severity != Warning {
+		t.Errorf("expected a long deferral to be a %v; got %v", Warning, exec.Severity)
+	}
+	deferral := <-w.Deferrals()
+	if deferral == nil {
+		t.Fatalf("expected a long deferral to be reported")
+	}
+	if waited := deferral.ExceededAt.Sub(deferral.Since); waited < 50*time.Millisecond {
+		t.Errorf("expected the deferral reported once it exceeded 50ms; reported after %v", waited)
+	}
+	if !deferral.ExceededAt.Before(exec.DispatchedAt) {
+		t.Errorf("expected the deferral reported while waiting; reported at %v, dispatched at %v",
+			deferral.ExceededAt, exec.DispatchedAt)
+	}
+}
+
+func TestTaskWindowDeferralStopped(t *testing.T) {
+	w := Watch(&Task{
+		Schedule:    10 * time.Millisecond,
+		Command:     func(time.Time) error { return nil },
+		Timeout:     1 * time.Second,
+		Window:      openingAt(time.Now().Add(1 * time.Hour)),
+		MaxDeferral: 20 * time.Millisecond,
+	})
+	discard(w)
+	var deferral *Deferral
+	select {
+	case deferral = <-w.Deferrals():
+	case <-time.After(1 * time.Second):
+	}
+	w.Stop()
+	if deferral == nil {
+		t.Fatalf("expected a deferral reported before the window opened")
+	}
+	if deferral.Severity != Warning {
+		t.Errorf("expected a deferral to be a %v; got %v", Warning, deferral.Severity)
+	}
+	if _, ok := <-w.Deferrals(); ok {
+		t.Errorf("expected a single deferral for the execution cut short by Stop")
+	}
+}
+
+func TestTaskWindowInaccurateNext(t *testing.T) {
+	opensAt := time.Now().Add(100 * time.Millisecond)
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+		// Claims to open early, then not at all
+		Window: nextFunc{openingAt(opensAt), func(now time.Time) time.Time {
+			if now.Before(opensAt.Add(-50 * time.Millisecond)) {
+				return opensAt.Add(-50 * time.Millisecond)
+			}
+			return now
+		}},
+	})
+	exec := <-w.Executions()
+	discard(w)
+	w.Stop()
+	if exec.DispatchedAt.Before(opensAt) {
+		t.Errorf("expected no executions before the window opened at %v; dispatched at %v",
+			opensAt, exec.DispatchedAt)
+	}
+}
+
+// Window with the given Next, regardless of what it contains
+type nextFunc struct {
+	Calendar
+	next func(time.Time) time.Time
+}
+
+func (n nextFunc) Next(t time.Time) time.Time {
+	return n.next(t)
+}
+
+// Window opening for good at the given time
+type openingAt time.Time
+
+func (o openingAt) Contains(t time.Time) bool {
+	return !t.Before(time.Time(o))
+}
+
+func (o openingAt) Next(t time.Time) time.Time {
+	if o.Contains(t) {
+		return t
+	}
+	return time.Time(o)
+}
+
+type calendarFunc func(time.Time) bool
+
+func (f calendarFunc) Contains(t time.Time) bool {
+	return f(t)
+}
@@ -0,0 +1,207 @@
+package watchdog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Information about each detected wall-clock adjustment
+type ClockJump struct {
+	// Wall-clock time the jump was detected at (after the jump)
+	DetectedAt time.Time
+	// How far the wall clock moved relative to elapsed monotonic
+	// time: positive if it jumped forward, negative if backward
+	Offset time.Duration
+	// Warning for jumps of a minute or more, Info otherwise
+	Severity Severity
+}
+
+// Information about each detected system suspend. Only reported
+// where the platform exposes time spent suspended (currently Linux);
+// elsewhere a resume may instead appear as a forward ClockJump.
+type Resume struct {
+	// Time the suspend was detected at (after resuming)
+	ResumedAt time.Time
+	// Approximately how long the system was suspended
+	Suspended time.Duration
+	// Always Info: suspends are expected on laptops and desktops
+	Severity Severity
+}
+
+// Source of time for the scheduler: the wall clock, or simulated
+// time (see Simulate)
+type clock interface {
+	now() time.Time
+	newTicker(d time.Duration) timer
+	newTimer(d time.Duration) timer
+	// Shortest duration timers can reliably measure
+	resolution() time.Duration
+	// Shortest Schedule the clock can keep to
+	minSchedule() time.Duration
+}
+
+// A ticker or timer created by a clock
+type timer interface {
+	channel() <-chan time.Time
+	reset(d time.Duration)
+	stop()
+}
+
+type wallClock struct{}
+
+func (wallClock) now() time.Time {
+	return time.Now()
+}
+
+func (wallClock) newTicker(d time.Duration) timer {
+	return wallTicker{time.NewTicker(d)}
+}
+
+func (wallClock) newTimer(d time.Duration) timer {
+	return wallTimer{time.NewTimer(d)}
+}
+
+func (wallClock) resolution() time.Duration {
+	return timerResolution()
+}
+
+// Fixed per platform rather than measured, so that whether a Schedule
+// is accepted does not depend on how busy the host is
+func (wallClock) minSchedule() time.Duration {
+	return scheduleFloor
+}
+
+// Timer resolution of the host, measured once as the shortest of a
+// few very short sleeps: coarse on some platforms and virtual machines
+// (around 15.6ms by default on Windows). Since a sleep has overhead of
+// its own, this is an upper bound, and noisy on a busy host.
+var hostResolution struct {
+	sync.Once
+	d time.Duration
+}
+
+func timerResolution() time.Duration {
+	hostResolution.Do(func() {
+		for i := 0; i < 10; i++ {
+			start := time.Now()
+			time.Sleep(1 * time.Microsecond)
+			if elapsed := time.Since(start); i == 0 || elapsed < hostResolution.d {
+				hostResolution.d = elapsed
+			}
+			// No need to keep sampling a fine-grained clock
+			if hostResolution.d <= 100*time.Microsecond {
+				break
+			}
+		}
+	})
+	return hostResolution.d
+}
+
+type wallTicker struct {
+	*time.Ticker
+}
+
+func (t wallTicker) channel() <-chan time.Time {
+	return t.C
+}
+
+func (t wallTicker) reset(d time.Duration) {
+	t.Reset(d)
+}
+
+func (t wallTicker) stop() {
+	t.Stop()
+}
+
+type wallTimer struct {
+	*time.Timer
+}
+
+func (t wallTimer) channel() <-chan time.Time {
+	return t.C
+}
+
+func (t wallTimer) reset(d time.Duration) {
+	t.Reset(d)
+}
+
+func (t wallTimer) stop() {
+	t.Stop()
+}
+
+const (
+	// How often to compare the wall and monotonic clocks
+	clockCheckInterval = 1 * time.Second
+	// Smallest discrepancy reported as a ClockJump
+	clockJumpThreshold = 1 * time.Second
+)
+
+// A reading of the clocks: the wall clock, monotonic time elapsed
+// since an arbitrary base, and (if available) time since boot
+// including time spent suspended
+type clockReading struct {
+	wall time.Time
+	mono time.Duration
+	boot time.Duration
+}
+
+func readClock(base time.Time) clockReading {
+	now := time.Now()
+	boot, _ := bootTime()
+	return clockReading{now.Round(0), now.Sub(base), boot}
+}
+
+// How far the wall clock moved between two readings beyond the
+// monotonic time that elapsed
+func clockOffset(prev, curr clockReading) time.Duration {
+	return curr.wall.Sub(prev.wall) - (curr.mono - prev.mono)
+}
+
+// How long the system was suspended between two readings: the
+// monotonic clock stops while suspended, but boot time does not
+func suspendedTime(prev, curr clockReading) time.Duration {
+	if prev.boot == 0 || curr.boot == 0 {
+		return 0
+	}
+	return (curr.boot - prev.boot) - (curr.mono - prev.mono)
+}
+
+func (w *Watchdog) monitorClock() {
+	base := time.Now()
+	prev := readClock(base)
+	ticker := time.NewTicker(clockCheckInterval)
+loop:
+	for {
+		select {
+		case <-w.done:
+			ticker.Stop()
+			break loop
+		case <-ticker.C:
+			curr := readClock(base)
+			// The wall clock keeps running while suspended, so
+			// only report what the suspend doesn't explain
+			suspended := suspendedTime(prev, curr)
+			if suspended >= clockJumpThreshold {
+				select {
+				case w.resumes <- &Resume{curr.wall, suspended, Info}:
+				default:
+					atomic.AddUint64(&w.droppedResumes, 1)
+				}
+			} else {
+				suspended = 0
+			}
+			offset := clockOffset(prev, curr) - suspended
+			if offset >= clockJumpThreshold || offset <= -clockJumpThreshold {
+				select {
+				case w.clockJumps <- &ClockJump{curr.wall, offset,
+					clockJumpSeverity(offset)}:
+				default:
+					atomic.AddUint64(&w.droppedClockJumps, 1)
+				}
+			}
+			prev = curr
+		}
+	}
+	w.sync.Done()
+}
@@ -0,0 +1,108 @@
+package watchdog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClockOffset(t *testing.T) {
+	wall := time.Date(2014, 1, 1, 12, 0, 0, 0, time.UTC)
+	prev := clockReading{wall, 10 * time.Second, 0}
+	for _, tc := range []struct {
+		wallElapsed time.Duration
+		monoElapsed time.Duration
+		expected    time.Duration
+	}{
+		{1 * time.Second, 1 * time.Second, 0},
+		{1 * time.Hour, 1 * time.Second, 1*time.Hour - 1*time.Second},
+		{-5 * time.Second, 1 * time.Second, -6 * time.Second},
+	} {
+		curr := clockReading{wall.Add(tc.wallElapsed), prev.mono + tc.monoElapsed, 0}
+		if offset := clockOffset(prev, curr); offset != tc.expected {
+			t.Errorf("wall elapsed %v, monotonic elapsed %v: expected offset %v; got %v",
+				tc.wallElapsed, tc.monoElapsed, tc.expected, offset)
+		}
+	}
+}
+
+func TestClockJumpsClosedOnStop(t *testing.T) {
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+	})
+	discard(w)
+	w.Stop()
+	select {
+	case jump, ok := <-w.ClockJumps():
+		if ok {
+			t.Errorf("expected no clock jumps; got %v", jump)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("expected ClockJumps channel to be closed after Stop")
+	}
+}
+
+func TestSuspendedTime(t *testing.T) {
+	wall := time.Date(2014, 1, 1, 12, 0, 0, 0, time.UTC)
+	prev := clockReading{wall, 10 * time.Second, 1 * time.Hour}
+	for _, tc := range []struct {
+		curr     clockReading
+		expected time.Duration
+	}{
+		{clockReading{wall.Add(1 * time.Second), 11 * time.Second, 1*time.Hour + 1*time.Second}, 0},
+		{clockReading{wall.Add(1 * time.Hour), 11 * time.Second, 2*time.Hour + 1*time.Second}, 1 * time.Hour},
+		{clockReading{wall.Add(1 * time.Hour), 11 * time.Second, 0}, 0},
+	} {
+		if suspended := suspendedTime(prev, tc.curr); suspended != tc.expected {
+			t.Errorf("readings %v to %v: expected %v suspended; got %v",
+				prev, tc.curr, tc.expected, suspended)
+		}
+	}
+}
+
+func TestBootTimeAdvances(t *testing.T) {
+	first, ok := bootTime()
+	if !ok {
+		t.Skip("boot time not available on this platform")
+	}
+	<-time.After(10 * time.Millisecond)
+	second, _ := bootTime()
+	if elapsed := second - first; elapsed < 10*time.Millisecond {
+		t.Errorf("expected boot time to advance by at least 10ms; advanced %v", elapsed)
+	}
+}
+
+func TestTimerResolution(t *testing.T) {
+	resolution := timerResolution()
+	if resolution <= 0 || resolution > 100*time.Millisecond {
+		t.Errorf("expected a plausible timer resolution; got %v", resolution)
+	}
+	sim := Simulate(simStart)
+	defer sim.Stop()
+	if s := sim.Snapshot(); s.ClockResolution != 0 {
+		t.Errorf("expected no resolution limit for simulated time; got %v", s.ClockResolution)
+	}
+
+	task := &Task{
+		Schedule: scheduleFloor / 2,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+	}
+	err := Validate(task)
+	if err == nil || !strings.Contains(err.Error(), "shorter than the timer resolution") {
+		t.Errorf("expected a schedule below the timer resolution to be rejected; got %v", err)
+	}
+	if _, msg := tryWatch(task); !strings.Contains(msg, "shorter than the timer resolution") {
+		t.Errorf("expected Watch to reject a schedule below the timer resolution; got %q", msg)
+	}
+	// Simulated time has no such limit
+	sim = Simulate(simStart, task)
+	defer sim.Stop()
+	discard(sim)
+	task.Schedule = scheduleFloor
+	if err := Validate(task); err == nil || !strings.Contains(err.Error(), "already being watched") {
+		t.Errorf("expected a schedule at the floor to be accepted; got %v", err)
+	}
+}
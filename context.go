@@ -0,0 +1,150 @@
+package watchdog
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Watch work running under a context with a deadline, and report a
+// Stall on the Watchdog's Stalls channel if the work outlives the
+// deadline: that is, if the returned function has not been called by
+// the time the context expires. Contexts cancelled before their
+// deadline are not reported. This catches work that ignores its
+// context, using the same event pipeline as scheduled tasks. Call the
+// returned function once the work completes; if it had stalled, an
+// Execution with the context's error is sent as well.
+//
+// Events are reported against a new Task with the given Name and a
+// Timeout of the time remaining until the deadline; the Task is not
+// scheduled or registered. Deadlines pass in real time, even under
+// Simulate, but events are stamped with the Watchdog's own clock. Contexts without a deadline are not
+// watched, nor is anything once the Watchdog is stopping.
+func (w *Watchdog) WatchContextDeadline(ctx context.Context, name string) func() {
+	deadline, ok := ctx.Deadline()
+	if !ok || !w.track() {
+		return func() {}
+	}
+	// Contexts expire in real time, but events are stamped on the
+	// Watchdog's clock, like those of its scheduled tasks
+	startedAt := w.clock.now()
+	task := &Task{Name: name, Timeout: time.Until(deadline)}
+	finished := make(chan bool)
+	go func() {
+		defer w.sync.Done()
+		select {
+		case <-finished:
+			return
+		case <-w.done:
+			return
+		case <-ctx.Done():
+			// Work cancelled before its deadline is not late
+			if ctx.Err() != context.DeadlineExceeded {
+				return
+			}
+			w.sendStall(&Stall{Task: task, StartedAt: startedAt,
+				StalledAt: w.clock.now(), Severity: Warning})
+		}
+		select {
+		case <-finished:
+			w.sendExecution(&Execution{
+				Task:         task,
+				StartedAt:    startedAt,
+				DispatchedAt: startedAt,
+				FinishedAt:   w.clock.now(),
+				Error:        ctx.Err(),
+				Severity:     Warning,
+			}, nil)
+		case <-w.done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(finished) })
+	}
+}
+
+// Why an execution's context was cancelled. A CancelReason is the
+// cause of the cancelled context (see context.Cause), so that Commands
+// can check it with errors.Is.
+type CancelReason int
+
+const (
+	// The context was not cancelled
+	NotCancelled CancelReason = iota
+	// The execution stalled: it outlived the Task Timeout
+	CancelTimeout
+	// The Watchdog was stopped
+	CancelStop
+	// The execution used up its latency budget: it reached the time
+	// the Task is next scheduled for before its Timeout
+	CancelBudget
+)
+
+func (r CancelReason) String() string {
+	switch r {
+	case NotCancelled:
+		return "not cancelled"
+	case CancelTimeout:
+		return "timeout"
+	case CancelStop:
+		return "stop"
+	case CancelBudget:
+		return "budget exhausted"
+	}
+	return "unknown"
+}
+
+func (r CancelReason) Error() string {
+	return "watchdog: execution cancelled: " + r.String()
+}
+
+// Context for an execution of a Task with a CommandContext. On the
+// wall clock, its deadline is the end of the execution's latency
+// budget: its Timeout, or the time the Task is next scheduled for if
+// that comes first, since executions of a Task never overlap. Tasks
+// without a Timeout only have the latter.
+func (w *Watchdog) executionContext(config *Task, startedAt, dispatchedAt time.Time) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	if w.simulated() {
+		return ctx, cancel
+	}
+	periods := dispatchedAt.Sub(startedAt)/config.Schedule + 1
+	deadline, reason := startedAt.Add(periods*config.Schedule), CancelBudget
+	if timeout := dispatchedAt.Add(config.Timeout); config.Timeout > 0 && timeout.Before(deadline) {
+		deadline, reason = timeout, CancelTimeout
+	}
+	ctx, stop := context.WithDeadlineCause(ctx, deadline, reason)
+	return ctx, func(cause error) {
+		cancel(cause)
+		stop()
+	}
+}
+
+// Remaining latency budget of an execution, given the context passed
+// to its CommandContext: the time left until its deadline, for sizing
+// the timeouts of its own sub-operations. Returns false if the context
+// has no deadline (e.g. under Simulate).
+func Budget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// Invoke the Task's Command, or its CommandContext with the given
+// context, returning why that context was cancelled, if it was
+func invoke(ctx context.Context, cancel context.CancelCauseFunc, config *Task, startedAt time.Time) (CancelReason, error) {
+	if config.CommandContext == nil {
+		return NotCancelled, guard(config.Panics, func() error {
+			return config.Command(startedAt)
+		})
+	}
+	err := guard(config.Panics, func() error {
+		return config.CommandContext(ctx, startedAt)
+	})
+	reason, _ := context.Cause(ctx).(CancelReason)
+	cancel(nil)
+	return reason, err
+}
@@ -0,0 +1,276 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWatchContextDeadline(t *testing.T) {
+	w := Watch()
+	defer w.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := w.WatchContextDeadline(ctx, "ignores-deadline")
+	var stall *Stall
+	select {
+	case stall = <-w.Stalls():
+	case <-time.After(1 * time.Second):
+		t.Fatalf("expected a stall once work outlived its deadline")
+	}
+	if stall.Task.Name != "ignores-deadline" {
+		t.Errorf("expected stall for the named work; got %q", stall.Task.Name)
+	}
+	if deadline, _ := ctx.Deadline(); stall.StalledAt.Before(deadline) {
+		t.Errorf("expected stall no earlier than the deadline %v; got %v",
+			deadline, stall.StalledAt)
+	}
+
+	done()
+	done()
+	select {
+	case exec := <-w.Executions():
+		if exec.Task != stall.Task || exec.Error != context.DeadlineExceeded {
+			t.Errorf("expected completion of the stalled work with %v; got %v for %v",
+				context.DeadlineExceeded, exec.Error, exec.Task)
+		}
+		if exec.Seq <= stall.Seq {
+			t.Errorf("expected completion after the stall; got seq %d after %d", exec.Seq, stall.Seq)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("expected an execution once stalled work completed")
+	}
+}
+
+func TestWatchContextDeadlineSimulated(t *testing.T) {
+	w := Simulate(simStart)
+	defer w.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := w.WatchContextDeadline(ctx, "simulated")
+	stall := <-w.Stalls()
+	if !stall.StartedAt.Equal(simStart) || !stall.StalledAt.Equal(simStart) {
+		t.Errorf("expected a stall stamped with simulated time %v; got %v to %v",
+			simStart, stall.StartedAt, stall.StalledAt)
+	}
+	w.Advance(1 * time.Second)
+	done()
+	if exec := <-w.Executions(); !exec.FinishedAt.Equal(simStart.Add(1 * time.Second)) {
+		t.Errorf("expected completion stamped with simulated time; got %v", exec.FinishedAt)
+	}
+}
+
+func TestWatchContextDeadlineCompleted(t *testing.T) {
+	w := Watch()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	w.WatchContextDeadline(ctx, "prompt")()
+	w.WatchContextDeadline(context.Background(), "no-deadline")
+	cancelled, cancelEarly := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	w.WatchContextDeadline(cancelled, "cancelled")
+	cancelEarly()
+	// Stopping abandons work still being watched
+	w.WatchContextDeadline(ctx, "abandoned")
+	<-time.After(40 * time.Millisecond)
+	select {
+	case stall := <-w.Stalls():
+		if stall.Task.Name != "abandoned" {
+			t.Errorf("expected no stall for completed, cancelled or unbounded work; got %q",
+				stall.Task.Name)
+		}
+	default:
+		t.Errorf("expected a stall for abandoned work")
+	}
+	w.Stop()
+	if done := w.WatchContextDeadline(ctx, "after-stop"); done == nil {
+		t.Errorf("expected a no-op function after Stop")
+	}
+	for stall := range w.Stalls() {
+		t.Errorf("expected no further stalls; got %q", stall.Task.Name)
+	}
+}
+
+func TestCommandContextTimeout(t *testing.T) {
+	causes := make(chan error, 1)
+	w := Watch(&Task{
+		Schedule: 50 * time.Millisecond,
+		CommandContext: func(ctx context.Context, _ time.Time) error {
+			<-ctx.Done()
+			select {
+			case causes <- context.Cause(ctx):
+			default:
+			}
+			return ctx.Err()
+		},
+		Timeout: 20 * time.Millisecond,
+	})
+	defer w.Stop()
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+
+	exec := <-w.Executions()
+	if exec.CancelReason != CancelTimeout {
+		t.Errorf("expected execution cancelled for %v; got %v", CancelTimeout, exec.CancelReason)
+	}
+	// The stall and the deadline race to cancel the context
+	if exec.Error != context.Canceled && exec.Error != context.DeadlineExceeded {
+		t.Errorf("expected the command's error; got %v", exec.Error)
+	}
+	if cause := <-causes; !errors.Is(cause, CancelTimeout) {
+		t.Errorf("expected context cause %v; got %v", CancelTimeout, cause)
+	}
+	go func() {
+		for range w.Executions() {
+		}
+	}()
+}
+
+func TestCommandContextNoTimeout(t *testing.T) {
+	w := Watch(&Task{
+		Schedule: 20 * time.Millisecond,
+		CommandContext: func(ctx context.Context, _ time.Time) error {
+			time.Sleep(5 * time.Millisecond)
+			return ctx.Err()
+		},
+	})
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		exec := <-w.Executions()
+		if exec.Error != nil || exec.CancelReason != NotCancelled {
+			t.Errorf("expected the command to run uncancelled; got %v (%v)", exec.Error, exec.CancelReason)
+		}
+	}
+	discard(w)
+	w.Stop()
+}
+
+func TestCommandContextStop(t *testing.T) {
+	started := make(chan bool, 1)
+	// Simulated time sets no deadlines, so only Stop cancels
+	w := Simulate(simStart, &Task{
+		Schedule: 10 * time.Millisecond,
+		CommandContext: func(ctx context.Context, _ time.Time) error {
+			select {
+			case started <- true:
+			default:
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Timeout: 1 * time.Hour,
+	})
+	w.Advance(10 * time.Millisecond)
+	<-started
+	stopped := make(chan bool)
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(1 * time.Second):
+		t.Fatalf("expected Stop to cancel the execution in flight")
+	}
+	reasons := 0
+	for exec := range w.Executions() {
+		if exec.CancelReason != CancelStop {
+			t.Errorf("expected execution cancelled for %v; got %v", CancelStop, exec.CancelReason)
+		}
+		reasons += 1
+	}
+	if reasons == 0 {
+		t.Errorf("expected an execution for the cancelled command")
+	}
+}
+
+func TestCommandContextBudget(t *testing.T) {
+	budgets := make(chan time.Duration, 1)
+	w := Watch(&Task{
+		Schedule: 50 * time.Millisecond,
+		CommandContext: func(ctx context.Context, _ time.Time) error {
+			budget, ok := Budget(ctx)
+			if !ok {
+				t.Errorf("expected a deadline")
+			}
+			select {
+			case budgets <- budget:
+			default:
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Timeout: 1 * time.Second,
+	})
+	defer w.Stop()
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+
+	exec := <-w.Executions()
+	if exec.CancelReason != CancelBudget {
+		t.Errorf("expected execution cancelled for %v; got %v", CancelBudget, exec.CancelReason)
+	}
+	if elapsed := exec.FinishedAt.Sub(exec.StartedAt); elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("expected execution cut short at the next tick; ran for %v", elapsed)
+	}
+	if budget := <-budgets; budget <= 0 || budget > 50*time.Millisecond {
+		t.Errorf("expected a budget up to the next tick; got %v", budget)
+	}
+	go func() {
+		for range w.Executions() {
+		}
+	}()
+
+	if _, ok := Budget(context.Background()); ok {
+		t.Errorf("expected no budget without a deadline")
+	}
+}
+
+func TestCommandContextBudgetNoTimeout(t *testing.T) {
+	budgets := make(chan time.Duration, 1)
+	w := Watch(&Task{
+		Schedule: 50 * time.Millisecond,
+		CommandContext: func(ctx context.Context, _ time.Time) error {
+			budget, ok := Budget(ctx)
+			if !ok {
+				t.Errorf("expected a deadline at the next tick")
+			}
+			select {
+			case budgets <- budget:
+			default:
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	defer w.Stop()
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+
+	exec := <-w.Executions()
+	if exec.CancelReason != CancelBudget {
+		t.Errorf("expected execution cancelled for %v; got %v", CancelBudget, exec.CancelReason)
+	}
+	if elapsed := exec.FinishedAt.Sub(exec.StartedAt); elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("expected execution cut short at the next tick; ran for %v", elapsed)
+	}
+	if budget := <-budgets; budget <= 0 || budget > 50*time.Millisecond {
+		t.Errorf("expected a budget up to the next tick; got %v", budget)
+	}
+	go func() {
+		for range w.Executions() {
+		}
+	}()
+}
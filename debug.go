@@ -0,0 +1,51 @@
+package watchdog
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Handler serving a human-readable dump of the Watchdog's internal
+// state, for troubleshooting the Watchdog itself in the style of
+// net/http/pprof:
+//
+//	http.Handle("/debug/watchdog", w.DebugHandler())
+func (w *Watchdog) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeSnapshot(rw, w.Snapshot())
+	})
+}
+
+func writeSnapshot(out io.Writer, s Snapshot) {
+	fmt.Fprintf(out, "watchdog snapshot at %v\n\n", s.TakenAt)
+	fmt.Fprintf(out, "executions backlog: %d\n", s.ExecutionsBacklog)
+	fmt.Fprintf(out, "stalls backlog: %d\n", s.StallsBacklog)
+	fmt.Fprintf(out, "dropped clock jumps: %d\n", s.DroppedClockJumps)
+	fmt.Fprintf(out, "dropped resumes: %d\n", s.DroppedResumes)
+	fmt.Fprintf(out, "dropped deferrals: %d\n", s.DroppedDeferrals)
+	fmt.Fprintf(out, "clock resolution: %v\n", s.ClockResolution)
+	for _, t := range s.Tasks {
+		fmt.Fprintf(out, "\n%s: schedule %v, timeout %v\n",
+			describe(t.Task), t.Schedule, t.Timeout)
+		if t.Running {
+			state := "running"
+			if t.Stalled {
+				state = "stalled"
+			}
+			fmt.Fprintf(out, "  in flight: %s for %v (scheduled for %v)\n",
+				state, s.TakenAt.Sub(t.DispatchedAt).Round(time.Millisecond), t.StartedAt)
+		} else {
+			fmt.Fprintf(out, "  in flight: none\n")
+		}
+		fmt.Fprintf(out, "  queued: %v\n", t.Queued)
+		fmt.Fprintf(out, "  executions: %d, failures: %d, stalls: %d, dropped ticks: %d\n",
+			t.Executions, t.Failures, t.Stalls, t.DroppedTicks)
+		if last := t.Last; last != nil {
+			fmt.Fprintf(out, "  last execution: scheduled for %v, took %v, error: %v\n",
+				last.StartedAt, last.FinishedAt.Sub(last.DispatchedAt), last.Error)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package watchdog
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	w := Simulate(simStart, &Task{
+		Name:     "debugged",
+		Schedule: 1 * time.Second,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+	})
+	w.Advance(1 * time.Second)
+	<-w.Executions()
+
+	server := httptest.NewServer(w.DebugHandler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL + "/debug/watchdog")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, expected := range []string{
+		`task "debugged": schedule 1s, timeout 1s`,
+		"in flight: none",
+		"executions: 1, failures: 0, stalls: 0",
+		"executions backlog: 0",
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected debug output to contain %q; got:\n%s", expected, body)
+		}
+	}
+
+	discard(w)
+	w.Stop()
+}
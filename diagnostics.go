@@ -0,0 +1,156 @@
+package watchdog
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// Settings for capturing a diagnostics bundle automatically when
+// things go badly wrong, so that evidence is collected before anyone
+// is around to collect it. See Watchdog.CaptureDiagnostics.
+type Diagnostics struct {
+	// Capture a bundle whenever a Critical task stalls
+	OnCriticalStall bool
+	// Capture a bundle once Stalls stalls (of any task) happen
+	// within Window; zero to disable
+	Stalls int
+	Window time.Duration
+	// Additional runtime/pprof profiles to include by name (e.g.
+	// "heap", "mutex"); a goroutine dump is always included
+	Profiles []string
+	// Destination for bundles
+	Uploader Uploader
+	// Optional function called with any error capturing or
+	// uploading a bundle
+	OnError func(error)
+}
+
+// Destination for diagnostics bundles, e.g. a directory or an object
+// store
+type Uploader interface {
+	// Store a bundle (a zip archive) under the given file name
+	Upload(name string, bundle []byte) error
+}
+
+// Uploader writing bundles to files in a directory
+type DirUploader string
+
+func (dir DirUploader) Upload(name string, bundle []byte) error {
+	return os.WriteFile(filepath.Join(string(dir), name), bundle, 0644)
+}
+
+// Trigger state for a Watchdog's diagnostics
+type diagnostics struct {
+	sync.Mutex
+	settings *Diagnostics
+	// Times of recent stalls within the window
+	stalls []time.Time
+	// Bundles being captured or uploaded
+	pending sync.WaitGroup
+}
+
+// Start capturing diagnostics bundles with the given settings, or
+// stop if nil. Bundles are captured and uploaded in the background;
+// Stop waits for any in progress to finish. Panics if the settings
+// have no Uploader.
+func (w *Watchdog) CaptureDiagnostics(d *Diagnostics) {
+	if d != nil && d.Uploader == nil {
+		panic("watchdog: CaptureDiagnostics called without an Uploader")
+	}
+	w.diagnostics.Lock()
+	defer w.diagnostics.Unlock()
+	w.diagnostics.settings = d
+	w.diagnostics.stalls = nil
+}
+
+// Check whether a stall should trigger a diagnostics bundle
+func (w *Watchdog) diagnoseStall(s *Stall, critical bool) {
+	w.diagnostics.Lock()
+	defer w.diagnostics.Unlock()
+	d := w.diagnostics.settings
+	if d == nil {
+		return
+	}
+	reason := ""
+	if critical && d.OnCriticalStall {
+		reason = fmt.Sprintf("critical %s stalled at %v", describe(s.Task), s.StalledAt)
+	}
+	if d.Stalls > 0 {
+		recent := w.diagnostics.stalls[:0]
+		for _, at := range w.diagnostics.stalls {
+			if s.StalledAt.Sub(at) < d.Window {
+				recent = append(recent, at)
+			}
+		}
+		recent = append(recent, s.StalledAt)
+		w.diagnostics.stalls = recent
+		if len(recent) >= d.Stalls {
+			if reason == "" {
+				reason = fmt.Sprintf("%d stalls within %v, last at %v",
+					len(recent), d.Window, s.StalledAt)
+			}
+			w.diagnostics.stalls = nil
+		}
+	}
+	if reason == "" {
+		return
+	}
+	w.diagnostics.pending.Add(1)
+	go func() {
+		defer w.diagnostics.pending.Done()
+		name := fmt.Sprintf("watchdog-%s.zip", s.StalledAt.UTC().Format("20060102T150405.000000000Z"))
+		bundle, err := w.DiagnosticsBundle(reason, d.Profiles...)
+		if err == nil {
+			err = d.Uploader.Upload(name, bundle)
+		}
+		if err != nil && d.OnError != nil {
+			d.OnError(fmt.Errorf("watchdog: capturing diagnostics: %w", err))
+		}
+	}()
+}
+
+// Build a diagnostics bundle now: a zip archive holding the given
+// reason, a Snapshot of the Watchdog (including each task's most
+// recent execution), a goroutine dump, and the named runtime/pprof
+// profiles.
+func (w *Watchdog) DiagnosticsBundle(reason string, profiles ...string) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	file, err := archive.Create("reason.txt")
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(file, reason)
+	if file, err = archive.Create("snapshot.txt"); err != nil {
+		return nil, err
+	}
+	writeSnapshot(file, w.Snapshot())
+	if file, err = archive.Create("goroutines.txt"); err != nil {
+		return nil, err
+	}
+	if err = pprof.Lookup("goroutine").WriteTo(file, 2); err != nil {
+		return nil, err
+	}
+	for _, name := range profiles {
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			return nil, fmt.Errorf("watchdog: no such profile %q", name)
+		}
+		if file, err = archive.Create(name + ".pb.gz"); err != nil {
+			return nil, err
+		}
+		if err = profile.WriteTo(file, 0); err != nil {
+			return nil, err
+		}
+	}
+	if err = archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,129 @@
+package watchdog
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testUploader struct {
+	mu      sync.Mutex
+	bundles [][]byte
+}
+
+func (u *testUploader) Upload(name string, bundle []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.bundles = append(u.bundles, bundle)
+	return nil
+}
+
+// Watch the given tasks in simulated time, stall each of them once,
+// then stop
+func stallOnce(d *Diagnostics, tasks ...*Task) {
+	started := make(chan bool, len(tasks))
+	release := make(chan bool)
+	for _, task := range tasks {
+		task.Schedule = 1 * time.Second
+		task.Timeout = 500 * time.Millisecond
+		task.Command = func(time.Time) error {
+			select {
+			case started <- true:
+			default:
+			}
+			<-release
+			return nil
+		}
+	}
+	w := Simulate(simStart, tasks...)
+	w.CaptureDiagnostics(d)
+	w.Advance(1 * time.Second)
+	for range tasks {
+		<-started
+	}
+	w.Advance(1 * time.Second)
+	for range tasks {
+		<-w.Stalls()
+	}
+	close(release)
+	discard(w)
+	w.Stop()
+}
+
+func readBundle(t *testing.T, bundle []byte) map[string]string {
+	archive, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	if err != nil {
+		t.Fatalf("expected a zip archive: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		r, _ := f.Open()
+		contents, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(contents)
+	}
+	return files
+}
+
+func TestDiagnosticsOnCriticalStall(t *testing.T) {
+	uploader := &testUploader{}
+	stallOnce(&Diagnostics{
+		OnCriticalStall: true,
+		Profiles:        []string{"heap"},
+		Uploader:        uploader,
+	}, &Task{Name: "critical", Critical: true}, &Task{Name: "other"})
+
+	if len(uploader.bundles) != 1 {
+		t.Fatalf("expected 1 bundle for the critical stall; got %d", len(uploader.bundles))
+	}
+	files := readBundle(t, uploader.bundles[0])
+	if reason := files["reason.txt"]; !strings.Contains(reason, `critical task "critical" stalled`) {
+		t.Errorf("expected reason to name the critical task; got %q", reason)
+	}
+	if !strings.Contains(files["snapshot.txt"], `task "critical"`) {
+		t.Errorf("expected snapshot in bundle; got %q", files["snapshot.txt"])
+	}
+	if !strings.Contains(files["goroutines.txt"], "goroutine") {
+		t.Errorf("expected goroutine dump in bundle")
+	}
+	if _, ok := files["heap.pb.gz"]; !ok {
+		t.Errorf("expected heap profile in bundle")
+	}
+}
+
+func TestDiagnosticsOnStallRate(t *testing.T) {
+	dir := t.TempDir()
+	stallOnce(&Diagnostics{
+		Stalls:   2,
+		Window:   1 * time.Minute,
+		Uploader: DirUploader(dir),
+	}, &Task{Name: "a"}, &Task{Name: "b"}, &Task{Name: "c"})
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 bundle after 2 of 3 stalls; got %d", len(entries))
+	}
+	bundle, _ := os.ReadFile(dir + "/" + entries[0].Name())
+	if reason := readBundle(t, bundle)["reason.txt"]; !strings.Contains(reason, "2 stalls within 1m0s") {
+		t.Errorf("expected reason to give the stall rate; got %q", reason)
+	}
+}
+
+func TestDiagnosticsWithoutUploader(t *testing.T) {
+	w := Simulate(simStart)
+	defer w.Stop()
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected settings without an Uploader to be rejected")
+		}
+	}()
+	w.CaptureDiagnostics(&Diagnostics{OnCriticalStall: true})
+}
@@ -0,0 +1,85 @@
+package watchdog
+
+import (
+	"time"
+)
+
+// Differences between two Snapshots, for change-driven alerting on
+// top of periodic snapshots (see Diff)
+type SnapshotDiff struct {
+	// Time between the snapshots
+	Elapsed time.Duration
+	// Overall health status in each snapshot
+	HealthBefore HealthStatus
+	HealthAfter  HealthStatus
+	// Tasks only in the later or the earlier snapshot
+	Added   []*Task
+	Removed []*Task
+	// Changes to tasks in both snapshots, in the order of the later
+	// one; tasks that did not change are omitted
+	Changes []TaskChange
+}
+
+// Changes to a single task between two Snapshots
+type TaskChange struct {
+	Task *Task
+	// Whether the task was healthy in each snapshot (see
+	// TaskStatus.Healthy)
+	WasHealthy bool
+	Healthy    bool
+	// Increases in the task's totals
+	Executions   uint64
+	Failures     uint64
+	Stalls       uint64
+	DroppedTicks uint64
+}
+
+// Compare an earlier Snapshot with a later one. Tasks are matched by
+// identity, so Snapshots of different Watchdogs watching the same
+// Tasks may be compared; totals that went down (because the Task was
+// watched afresh) count from zero.
+func Diff(a, b Snapshot) SnapshotDiff {
+	d := SnapshotDiff{
+		Elapsed:      b.TakenAt.Sub(a.TakenAt),
+		HealthBefore: a.Health().Status,
+		HealthAfter:  b.Health().Status,
+	}
+	before := make(map[*Task]TaskStatus)
+	for _, t := range a.Tasks {
+		before[t.Task] = t
+	}
+	after := make(map[*Task]bool)
+	for _, t := range b.Tasks {
+		after[t.Task] = true
+		prev, ok := before[t.Task]
+		if !ok {
+			d.Added = append(d.Added, t.Task)
+			continue
+		}
+		change := TaskChange{
+			Task:         t.Task,
+			WasHealthy:   prev.Healthy(),
+			Healthy:      t.Healthy(),
+			Executions:   increase(prev.Executions, t.Executions),
+			Failures:     increase(prev.Failures, t.Failures),
+			Stalls:       increase(prev.Stalls, t.Stalls),
+			DroppedTicks: increase(prev.DroppedTicks, t.DroppedTicks),
+		}
+		if change != (TaskChange{Task: t.Task, WasHealthy: change.Healthy, Healthy: change.Healthy}) {
+			d.Changes = append(d.Changes, change)
+		}
+	}
+	for _, t := range a.Tasks {
+		if !after[t.Task] {
+			d.Removed = append(d.Removed, t.Task)
+		}
+	}
+	return d
+}
+
+func increase(before, after uint64) uint64 {
+	if after < before {
+		return after
+	}
+	return after - before
+}
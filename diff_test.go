@@ -0,0 +1,55 @@
+package watchdog
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	steady, failing, added, removed := newTestTask("steady"), newTestTask("failing"),
+		newTestTask("added"), newTestTask("removed")
+	a := Snapshot{
+		TakenAt: simStart,
+		Tasks: []TaskStatus{
+			{Task: steady, Executions: 3},
+			{Task: failing, Executions: 3},
+			{Task: removed},
+		},
+	}
+	b := Snapshot{
+		TakenAt: simStart.Add(1 * time.Minute),
+		Tasks: []TaskStatus{
+			{Task: added},
+			{Task: steady, Executions: 3},
+			{Task: failing, Executions: 5, Failures: 1, Stalls: 1,
+				Last: &Execution{Error: errors.New("oh snap")}},
+		},
+	}
+	d := Diff(a, b)
+	if d.Elapsed != 1*time.Minute {
+		t.Errorf("expected 1m between snapshots; got %v", d.Elapsed)
+	}
+	if d.HealthBefore != HealthOK || d.HealthAfter == HealthOK {
+		t.Errorf("expected health to get worse; went from %v to %v", d.HealthBefore, d.HealthAfter)
+	}
+	if len(d.Added) != 1 || d.Added[0] != added {
+		t.Errorf("expected only %v added; got %v", describe(added), d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0] != removed {
+		t.Errorf("expected only %v removed; got %v", describe(removed), d.Removed)
+	}
+	if len(d.Changes) != 1 {
+		t.Fatalf("expected only the failing task to change; got %+v", d.Changes)
+	}
+	expected := TaskChange{Task: failing, WasHealthy: true, Healthy: false,
+		Executions: 2, Failures: 1, Stalls: 1}
+	if d.Changes[0] != expected {
+		t.Errorf("expected %+v; got %+v", expected, d.Changes[0])
+	}
+
+	// Totals count afresh when a task is watched again
+	if d := Diff(b, a); len(d.Changes) != 1 || d.Changes[0].Executions != 3 {
+		t.Errorf("expected reset totals to count from zero; got %+v", d.Changes)
+	}
+}
@@ -6,12 +6,17 @@ Watchdog accepts a workload and runs it at the specified interval.
 Watchdog exposes two channels for monitoring its workload: Executions
 and Stalls. Executions are sent for every invocation of a workload
 Task, and stalls are only sent if a Task invocation takes longer than
-a specified timeout.
+a specified timeout. Two further, optional channels, ClockJumps and
+Resumes, report wall-clock adjustments and system suspends; scheduling
+and stall detection rely on the monotonic clock and are unaffected by
+either.
 
 A Watchdog is created with the Watch method, and starts running its
 workload immediately. Its execution semantics are very close to those
 of time.Ticker: a single tick may be "queued up" at any time if the
-command takes longer to execute than the scheduling period.
+command takes longer to execute than the scheduling period. A
+Watchdog created with Simulate instead runs on simulated time, which
+only passes when its Advance method is called.
 
 A Watchdog may be stopped with the Stop command. If a task is
 currently executing, that task will complete before Stop returns, and
@@ -0,0 +1,55 @@
+package watchdog
+
+import (
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snapshot of the host environment taken when a Task fails or stalls
+// (see Task.CaptureEnvironment), so that alerts carry enough context to
+// triage remotely
+type Environment struct {
+	// Time the environment was captured
+	CapturedAt time.Time
+	Hostname   string
+	// Selected environment variables, by name; unset ones are
+	// omitted
+	Vars map[string]string
+	// Build information of the running binary, including module
+	// versions and VCS revision (nil if unavailable)
+	Build *debug.BuildInfo
+	// System load averages over 1, 5 and 15 minutes (zero where
+	// unavailable; currently only read on Linux)
+	LoadAverage [3]float64
+}
+
+func captureEnvironment(capturedAt time.Time, vars []string) *Environment {
+	env := &Environment{CapturedAt: capturedAt, Vars: make(map[string]string)}
+	env.Hostname, _ = os.Hostname()
+	for _, name := range vars {
+		if value, ok := os.LookupEnv(name); ok {
+			env.Vars[name] = value
+		}
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		env.Build = build
+	}
+	env.LoadAverage = loadAverage()
+	return env
+}
+
+// Read the load averages from /proc/loadavg, if it exists
+func loadAverage() (load [3]float64) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return load
+	}
+	fields := strings.Fields(string(data))
+	for i := 0; i < len(load) && i < len(fields); i++ {
+		load[i], _ = strconv.ParseFloat(fields[i], 64)
+	}
+	return load
+}
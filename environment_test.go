@@ -0,0 +1,72 @@
+package watchdog
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCaptureEnvironment(t *testing.T) {
+	t.Setenv("WATCHDOG_TEST_REGION", "eu-west-1")
+	at := time.Date(2014, 1, 1, 12, 0, 0, 0, time.UTC)
+	env := captureEnvironment(at, []string{"WATCHDOG_TEST_REGION", "WATCHDOG_TEST_UNSET"})
+	if !env.CapturedAt.Equal(at) {
+		t.Errorf("expected capture at %v; got %v", at, env.CapturedAt)
+	}
+	if hostname, _ := os.Hostname(); env.Hostname != hostname {
+		t.Errorf("expected hostname %q; got %q", hostname, env.Hostname)
+	}
+	if len(env.Vars) != 1 || env.Vars["WATCHDOG_TEST_REGION"] != "eu-west-1" {
+		t.Errorf("expected only the set variable; got %v", env.Vars)
+	}
+	for _, load := range env.LoadAverage {
+		if load < 0 {
+			t.Errorf("expected non-negative load averages; got %v", env.LoadAverage)
+		}
+	}
+}
+
+func TestTaskCaptureEnvironment(t *testing.T) {
+	calls := 0
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command: func(time.Time) error {
+			calls += 1
+			if calls == 1 {
+				return errors.New("oh snap")
+			}
+			return nil
+		},
+		Timeout:            1 * time.Second,
+		CaptureEnvironment: true,
+	})
+	failed, succeeded := <-w.Executions(), <-w.Executions()
+	discard(w)
+	w.Stop()
+	if failed.Environment == nil {
+		t.Errorf("expected an environment on failure")
+	}
+	if succeeded.Environment != nil {
+		t.Errorf("expected no environment on success; got %+v", succeeded.Environment)
+	}
+}
+
+func TestEnvironmentVarsCopiedOnWatch(t *testing.T) {
+	t.Setenv("WATCHDOG_TEST_REGION", "eu-west-1")
+	vars := []string{"WATCHDOG_TEST_REGION"}
+	w := Watch(&Task{
+		Schedule:           10 * time.Millisecond,
+		Command:            func(time.Time) error { return errors.New("oh snap") },
+		Timeout:            1 * time.Second,
+		CaptureEnvironment: true,
+		EnvironmentVars:    vars,
+	})
+	vars[0] = "WATCHDOG_TEST_UNSET"
+	exec := <-w.Executions()
+	discard(w)
+	w.Stop()
+	if exec.Environment.Vars["WATCHDOG_TEST_REGION"] != "eu-west-1" {
+		t.Errorf("expected the variables given to Watch; got %v", exec.Environment.Vars)
+	}
+}
@@ -0,0 +1,49 @@
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CommandContext for a Task checking that an object in storage, such
+// as a backup or export in an S3-compatible bucket, exists and was
+// modified less than maxAge before the time the execution was
+// scheduled for. The object is fetched with a HEAD request to the
+// given URL, and its age taken from the Last-Modified header.
+//
+// Requests are made with the given client, or http.DefaultClient if
+// nil; for private buckets, use a presigned URL or a client whose
+// Transport signs requests.
+func CheckObjectFreshness(client *http.Client, url string, maxAge time.Duration) func(context.Context, time.Time) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, scheduledAt time.Time) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return fmt.Errorf("watchdog: checking %s: %w", url, err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("watchdog: checking %s: %w", url, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("watchdog: %s is missing", url)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("watchdog: checking %s: %s", url, resp.Status)
+		}
+		modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+		if err != nil {
+			return fmt.Errorf("watchdog: %s has no valid Last-Modified time", url)
+		}
+		if age := scheduledAt.Sub(modified); age > maxAge {
+			return fmt.Errorf("watchdog: %s was last modified %v ago, more than %v",
+				url, age, maxAge)
+		}
+		return nil
+	}
+}
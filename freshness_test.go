@@ -0,0 +1,51 @@
+package watchdog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckObjectFreshness(t *testing.T) {
+	modified := time.Date(2014, 1, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("expected a HEAD request; got %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/backup.tar.gz":
+			rw.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		case "/undated":
+		case "/forbidden":
+			rw.WriteHeader(http.StatusForbidden)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	for i, tc := range []struct {
+		path     string
+		at       time.Time
+		expected string
+	}{
+		{"/backup.tar.gz", modified.Add(1 * time.Hour), ""},
+		{"/backup.tar.gz", modified.Add(25 * time.Hour), "last modified 25h0m0s ago"},
+		{"/missing", modified, "is missing"},
+		{"/undated", modified, "no valid Last-Modified"},
+		{"/forbidden", modified, "403 Forbidden"},
+	} {
+		check := CheckObjectFreshness(server.Client(), server.URL+tc.path, 24*time.Hour)
+		err := check(ctx, tc.at)
+		if tc.expected == "" && err != nil {
+			t.Errorf("case %d: expected no error; got %v", i, err)
+		}
+		if tc.expected != "" && (err == nil || !strings.Contains(err.Error(), tc.expected)) {
+			t.Errorf("case %d: expected error containing %q; got %v", i, tc.expected, err)
+		}
+	}
+}
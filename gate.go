@@ -0,0 +1,74 @@
+package watchdog
+
+import (
+	"sync"
+	"time"
+)
+
+// Information about a gate Task that has not yet succeeded within its
+// GateTimeout, holding back the schedules of the other Tasks
+type Degradation struct {
+	// Gate Task that has not succeeded
+	Task *Task
+	// Time the Watchdog started waiting for the gate
+	Since time.Time
+	// Time the gate timed out
+	DegradedAt time.Time
+	// Critical for critical Tasks, Warning otherwise
+	Severity Severity
+}
+
+// Startup gating: Tasks that are not gates wait for open to be closed
+// once every gate has succeeded
+type gating struct {
+	sync.Mutex
+	pending int
+	open    chan bool
+}
+
+// Channel of gates that did not succeed within their GateTimeout. At
+// most one Degradation is sent per gate, so this channel need not be
+// drained. The other Tasks keep waiting for the gate regardless.
+func (w *Watchdog) Degradations() <-chan *Degradation {
+	return w.degradations
+}
+
+// Whether every gate has succeeded
+func (w *Watchdog) gatesOpen() bool {
+	select {
+	case <-w.gating.open:
+		return true
+	default:
+		return false
+	}
+}
+
+// Record the first success of a gate, opening the gates once it was
+// the last one pending
+func (w *Watchdog) passGate(passed chan bool) {
+	close(passed)
+	w.gating.Lock()
+	defer w.gating.Unlock()
+	w.gating.pending -= 1
+	if w.gating.pending == 0 {
+		close(w.gating.open)
+	}
+}
+
+// Report a Degradation if the given gate does not pass within its
+// GateTimeout, counted from since on the given timer
+func (w *Watchdog) watchGate(task *Task, config Task, passed <-chan bool, since time.Time, timeout timer) {
+	defer w.sync.Done()
+	defer timeout.stop()
+	select {
+	case <-passed:
+	case <-w.done:
+	case degradedAt := <-timeout.channel():
+		severity := Warning
+		if config.Critical {
+			severity = Critical
+		}
+		w.degradations <- &Degradation{Task: task, Since: since,
+			DegradedAt: degradedAt, Severity: severity}
+	}
+}
@@ -0,0 +1,107 @@
+package watchdog
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGate(t *testing.T) {
+	attempts := 0
+	gate := &Task{
+		Schedule: 1 * time.Second,
+		Command: func(time.Time) error {
+			attempts += 1
+			if attempts == 1 {
+				return errors.New("not ready")
+			}
+			return nil
+		},
+		Timeout:     1 * time.Second,
+		Gate:        true,
+		GateTimeout: 5 * time.Second,
+	}
+	dependent := &Task{
+		Schedule: 1 * time.Second,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+	}
+	w := Simulate(simStart, gate, dependent)
+	defer w.Stop()
+
+	var dependentRuns []time.Time
+	for i := 1; i <= 3; i++ {
+		w.Advance(1 * time.Second)
+		timeout := time.After(50 * time.Millisecond)
+	receive:
+		for {
+			select {
+			case exec := <-w.Executions():
+				if exec.Task == dependent {
+					dependentRuns = append(dependentRuns, exec.StartedAt)
+				}
+			case <-timeout:
+				break receive
+			}
+		}
+	}
+	if len(dependentRuns) == 0 {
+		t.Fatalf("expected the dependent task to run once the gate succeeded")
+	}
+	for _, startedAt := range dependentRuns {
+		if opened := simStart.Add(2 * time.Second); startedAt.Before(opened) {
+			t.Errorf("expected no dependent executions before %v; got one at %v", opened, startedAt)
+		}
+	}
+	select {
+	case d := <-w.Degradations():
+		t.Errorf("expected no degradation once the gate opened; got %v", d)
+	default:
+	}
+}
+
+func TestGateTimeout(t *testing.T) {
+	gate := &Task{
+		Schedule:    1 * time.Second,
+		Command:     func(time.Time) error { return errors.New("not ready") },
+		Timeout:     1 * time.Second,
+		Gate:        true,
+		GateTimeout: 2500 * time.Millisecond,
+	}
+	dependent := &Task{
+		Schedule: 1 * time.Second,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+	}
+	w := Simulate(simStart, gate, dependent)
+	defer w.Stop()
+	go func() {
+		for exec := range w.Executions() {
+			if exec.Task == dependent {
+				t.Errorf("expected the dependent task not to run; ran at %v", exec.StartedAt)
+			}
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		w.Advance(1 * time.Second)
+	}
+	select {
+	case d := <-w.Degradations():
+		if d.Task != gate {
+			t.Errorf("expected degradation of the gate; got %v", describe(d.Task))
+		}
+		if !d.Since.Equal(simStart) {
+			t.Errorf("expected degradation since %v; got %v", simStart, d.Since)
+		}
+		if expected := simStart.Add(2500 * time.Millisecond); !d.DegradedAt.Equal(expected) {
+			t.Errorf("expected degradation at %v; got %v", expected, d.DegradedAt)
+		}
+		if d.Severity != Warning {
+			t.Errorf("expected Warning severity; got %v", d.Severity)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("expected a degradation once the gate timed out")
+	}
+	time.Sleep(50 * time.Millisecond)
+}
@@ -0,0 +1,3 @@
+module github.com/deafbybeheading/watchdog
+
+go 1.21
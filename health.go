@@ -0,0 +1,106 @@
+package watchdog
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Overall health of a Watchdog's tasks
+type HealthStatus int
+
+const (
+	// All tasks healthy
+	HealthOK HealthStatus = iota
+	// Some tasks failing or stalled, but not enough (by weight) to
+	// be critical
+	HealthDegraded
+	// A critical task, or at least half the total weight of tasks,
+	// failing or stalled
+	HealthCritical
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthOK:
+		return "ok"
+	case HealthDegraded:
+		return "degraded"
+	case HealthCritical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// Weighted summary of task states
+type Health struct {
+	Status HealthStatus
+	// Weighted fraction of tasks that are healthy, from 0 to 1
+	Score float64
+	// Tasks whose last execution failed or whose execution in
+	// flight has stalled
+	Unhealthy []*Task
+}
+
+// Whether a task is currently failing or stalled. Tasks which have
+// not completed an execution yet are given the benefit of the doubt.
+func (t TaskStatus) Healthy() bool {
+	if t.Running && t.Stalled {
+		return false
+	}
+	return t.Last == nil || t.Last.Error == nil
+}
+
+// Compute overall health from the tasks in the snapshot, weighting
+// each by its Weight.
+func (s Snapshot) Health() Health {
+	h := Health{Score: 1}
+	var total, healthy float64
+	critical := false
+	for _, t := range s.Tasks {
+		weight := t.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		if t.Healthy() {
+			healthy += weight
+			continue
+		}
+		h.Unhealthy = append(h.Unhealthy, t.Task)
+		if t.Critical {
+			critical = true
+		}
+	}
+	if total > 0 {
+		h.Score = healthy / total
+	}
+	switch {
+	case critical || h.Score <= 0.5:
+		h.Status = HealthCritical
+	case h.Score < 1:
+		h.Status = HealthDegraded
+	}
+	return h
+}
+
+// Current overall health of the Watchdog's tasks
+func (w *Watchdog) Health() Health {
+	return w.Snapshot().Health()
+}
+
+// Handler for health checks (e.g. at /healthz): responds 200 while
+// the Watchdog is OK or Degraded and 503 once it is Critical, with the
+// status, score and unhealthy tasks in the body.
+func (w *Watchdog) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		h := w.Health()
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if h.Status == HealthCritical {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprintf(rw, "%v (score %.2f)\n", h.Status, h.Score)
+		for _, task := range h.Unhealthy {
+			fmt.Fprintf(rw, "unhealthy: %s\n", describe(task))
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package watchdog
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSnapshotHealth(t *testing.T) {
+	ok := &Execution{}
+	failed := &Execution{Error: errors.New("oh snap")}
+	status := func(weight float64, critical bool, last *Execution, stalled bool) TaskStatus {
+		return TaskStatus{
+			Task:     &Task{},
+			Weight:   weight,
+			Critical: critical,
+			Last:     last,
+			Running:  stalled,
+			Stalled:  stalled,
+		}
+	}
+	for i, tc := range []struct {
+		tasks    []TaskStatus
+		status   HealthStatus
+		score    float64
+		failures int
+	}{
+		{nil, HealthOK, 1, 0},
+		{[]TaskStatus{status(0, false, nil, false)}, HealthOK, 1, 0},
+		{[]TaskStatus{status(0, false, ok, false), status(0, false, ok, false)}, HealthOK, 1, 0},
+		// A flaky, low-importance check only degrades health
+		{[]TaskStatus{status(9, false, ok, false), status(1, false, failed, false)},
+			HealthDegraded, 0.9, 1},
+		{[]TaskStatus{status(1, false, ok, false), status(1, false, failed, false)},
+			HealthCritical, 0.5, 1},
+		{[]TaskStatus{status(9, false, ok, false), status(1, true, nil, true)},
+			HealthCritical, 0.9, 1},
+		{[]TaskStatus{status(3, false, failed, false), status(1, false, ok, true)},
+			HealthCritical, 0, 2},
+	} {
+		h := Snapshot{Tasks: tc.tasks}.Health()
+		if h.Status != tc.status || h.Score != tc.score || len(h.Unhealthy) != tc.failures {
+			t.Errorf("case %d: expected %v (score %v, %d unhealthy); got %v (score %v, %d unhealthy)",
+				i, tc.status, tc.score, tc.failures, h.Status, h.Score, len(h.Unhealthy))
+		}
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	task := newTestTask("")
+	task.Critical = true
+	w := Simulate(simStart, task)
+	discard(w)
+	defer w.Stop()
+
+	rec := httptest.NewRecorder()
+	w.HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected %d before any failures; got %d", http.StatusOK, rec.Code)
+	}
+
+	w.statuses[0].update(func(s *TaskStatus) {
+		s.Last = &Execution{Task: task, Error: errors.New("oh snap")}
+	})
+	rec = httptest.NewRecorder()
+	w.HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d after a critical task failed; got %d",
+			http.StatusServiceUnavailable, rec.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package watchdog
+
+import (
+	"sync"
+)
+
+// Wrap a job-processing function, such as the body of a worker pool
+// or queue consumer, so that each call becomes an Execution of the
+// given Task: calls outliving the Task Timeout are reported as Stalls,
+// and Executions carry each call's error and Severity as for scheduled
+// Tasks, with panics handled according to its Panics policy. The
+// Task's Schedule and Command are unused, and it is neither scheduled
+// nor registered; its other fields are copied, as by Watch.
+//
+// The returned function may be called from any number of goroutines
+// at once, and returns the job's error. Stop waits for calls in
+// progress; calls made once the Watchdog is stopping run unwatched.
+func WatchJobs[J any](w *Watchdog, task *Task, process func(J) error) func(J) error {
+	config := copyTask(task)
+	var mu sync.Mutex
+	failures := 0
+	return func(job J) error {
+		if !w.track() {
+			return process(job)
+		}
+		defer w.sync.Done()
+		startedAt := w.clock.now()
+		stallTimer := w.clock.newTimer(config.Timeout)
+		finished := make(chan bool)
+		monitored := make(chan bool)
+		go func() {
+			defer close(monitored)
+			select {
+			case stalledAt := <-stallTimer.channel():
+				stall := &Stall{Task: task, StartedAt: startedAt,
+					StalledAt: stalledAt, Severity: stallSeverity(&config)}
+				if config.CaptureEnvironment {
+					stall.Environment = captureEnvironment(stalledAt, config.EnvironmentVars)
+				}
+				w.sendStall(stall)
+				w.diagnoseStall(stall, config.Critical)
+			case <-finished:
+			}
+		}()
+		err := func() error {
+			// Stop monitoring even if a panic propagates, so that
+			// no stall is sent once Stop has closed the channels
+			defer func() {
+				stallTimer.stop()
+				close(finished)
+				// Report any stall before the execution it
+				// belongs to
+				<-monitored
+			}()
+			return guard(config.Panics, func() error { return process(job) })
+		}()
+		e := &Execution{
+			Task:         task,
+			StartedAt:    startedAt,
+			DispatchedAt: startedAt,
+			FinishedAt:   w.clock.now(),
+			Error:        err,
+		}
+		if err != nil && config.CaptureEnvironment {
+			e.Environment = captureEnvironment(e.FinishedAt, config.EnvironmentVars)
+		}
+		mu.Lock()
+		if err != nil {
+			failures += 1
+		} else {
+			failures = 0
+		}
+		e.Severity = executionSeverity(&config, e, failures)
+		mu.Unlock()
+		w.sendExecution(e, nil)
+		return err
+	}
+}
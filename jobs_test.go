@@ -0,0 +1,98 @@
+package watchdog
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchJobs(t *testing.T) {
+	w := Watch()
+	task := &Task{Name: "worker", Timeout: 20 * time.Millisecond}
+	fail := errors.New("oh snap")
+	process := WatchJobs(w, task, func(d time.Duration) error {
+		time.Sleep(d)
+		if d > 0 {
+			return fail
+		}
+		return nil
+	})
+
+	var workers sync.WaitGroup
+	for _, d := range []time.Duration{0, 0, 50 * time.Millisecond} {
+		workers.Add(1)
+		go func(d time.Duration) {
+			defer workers.Done()
+			if err := process(d); (err != nil) != (d > 0) {
+				t.Errorf("job taking %v: unexpected error %v", d, err)
+			}
+		}(d)
+	}
+	go func() {
+		workers.Wait()
+		w.Stop()
+	}()
+
+	var stall *Stall
+	executions, failed := 0, 0
+	var failedSeq uint64
+	stalls, execs := w.Stalls(), w.Executions()
+	for stalls != nil || execs != nil {
+		select {
+		case s, ok := <-stalls:
+			if !ok {
+				stalls = nil
+				continue
+			}
+			stall = s
+		case e, ok := <-execs:
+			if !ok {
+				execs = nil
+				continue
+			}
+			if e.Task != task {
+				t.Errorf("expected executions of the given task; got %v", e.Task)
+			}
+			executions += 1
+			if e.Error != nil {
+				failed += 1
+				failedSeq = e.Seq
+			}
+		}
+	}
+	if executions != 3 || failed != 1 {
+		t.Errorf("expected 3 executions with 1 failure; got %d with %d", executions, failed)
+	}
+	if stall == nil {
+		t.Fatalf("expected a stall for the slow job")
+	}
+	if stall.StalledAt.Sub(stall.StartedAt) < task.Timeout {
+		t.Errorf("expected stall no earlier than the timeout; stalled after %v",
+			stall.StalledAt.Sub(stall.StartedAt))
+	}
+	if stall.Seq >= failedSeq {
+		t.Errorf("expected the stall before its execution; got seq %d and %d", stall.Seq, failedSeq)
+	}
+	if err := process(0); err != nil {
+		t.Errorf("expected jobs to run unwatched after Stop; got %v", err)
+	}
+}
+
+func TestWatchJobsPropagatedPanic(t *testing.T) {
+	w := Watch()
+	process := WatchJobs(w, &Task{Timeout: 20 * time.Millisecond, Panics: PropagatePanics},
+		func(int) error { panic("oh snap") })
+	func() {
+		defer func() {
+			if r := recover(); r != "oh snap" {
+				t.Errorf("expected the panic to propagate; got %v", r)
+			}
+		}()
+		process(0)
+	}()
+	discard(w)
+	w.Stop()
+	// A stall timer left running would now send on a closed channel
+	time.Sleep(50 * time.Millisecond)
+}
@@ -0,0 +1,62 @@
+package watchdog
+
+import (
+	"context"
+	"time"
+)
+
+// Mutual exclusion for a Task shared between several Watchdog
+// instances, such as replicas of the same service. Implementations
+// are typically backed by etcd, Redis (Redlock-style), or Postgres
+// advisory locks; each Task should be given its own Lock.
+type Lock interface {
+	// Called before every execution. Reports whether this
+	// instance holds the lock, acquiring it if it is free; an
+	// instance that already holds the lock should renew any lease
+	// and return true. Must not block waiting for the lock, and
+	// should give up with the context's error once it is done:
+	// after the Task Timeout, or when the Watchdog stops.
+	TryLock(ctx context.Context) (bool, error)
+	// Called once the Watchdog stops, if the lock was ever
+	// acquired. Implementations should let the lock expire if it
+	// cannot be released.
+	Unlock()
+}
+
+// Shared record of which periods of a Task have already executed,
+// used to run long-period tasks at most once per period across a
+// fleet, even through restarts. Implementations must claim
+// transactionally, e.g. with a conditional insert into a shared
+// database; each Task should be given its own Ledger.
+type Ledger interface {
+	// Claim the period starting at the given time (the scheduled
+	// time truncated to a multiple of the Task Schedule), and
+	// report whether this instance now owns it. Must return false
+	// if any instance has claimed the period before, and should
+	// give up with the context's error once it is done: after the
+	// Task Timeout, or when the Watchdog stops.
+	Claim(ctx context.Context, period time.Time) (bool, error)
+}
+
+// Context for calling a Task's Lock or Ledger: done once the Task
+// Timeout has passed (on the wall clock) or the Watchdog stops, so
+// that a store which hangs fails the execution rather than silently
+// stopping the Task before it can stall
+func (w *Watchdog) storeContext(config *Task) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-w.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if config.Timeout <= 0 || w.simulated() {
+		return ctx, cancel
+	}
+	timeout, stop := context.WithTimeout(ctx, config.Timeout)
+	return timeout, func() {
+		stop()
+		cancel()
+	}
+}
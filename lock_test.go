@@ -0,0 +1,254 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testLock struct {
+	mu       sync.Mutex
+	held     bool
+	err      error
+	attempts int
+	unlocked bool
+}
+
+func (l *testLock) TryLock(context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts += 1
+	return l.held, l.err
+}
+
+func (l *testLock) Unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unlocked = true
+}
+
+func watchLocked(lock *testLock, runs *int) []*Execution {
+	var mu sync.Mutex
+	w := Watch(&Task{
+		Schedule: 20 * time.Millisecond,
+		Command: func(time.Time) error {
+			mu.Lock()
+			*runs += 1
+			mu.Unlock()
+			return nil
+		},
+		Timeout: 1 * time.Second,
+		Lock:    lock,
+	})
+	var execs []*Execution
+	done := make(chan bool)
+	go func() {
+		for e := range w.Executions() {
+			execs = append(execs, e)
+		}
+		done <- true
+	}()
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+	<-time.After(70 * time.Millisecond)
+	w.Stop()
+	<-done
+	return execs
+}
+
+func TestLockHeld(t *testing.T) {
+	lock := &testLock{held: true}
+	runs := 0
+	execs := watchLocked(lock, &runs)
+	if runs == 0 || len(execs) != runs {
+		t.Errorf("expected every execution to run and be reported; ran %d, reported %d",
+			runs, len(execs))
+	}
+	if !lock.unlocked {
+		t.Errorf("expected lock to be released on Stop")
+	}
+}
+
+func TestLockNotHeld(t *testing.T) {
+	lock := &testLock{held: false}
+	runs := 0
+	execs := watchLocked(lock, &runs)
+	if lock.attempts == 0 {
+		t.Errorf("expected lock to be attempted")
+	}
+	if runs != 0 || len(execs) != 0 {
+		t.Errorf("expected no executions without the lock; ran %d, reported %d",
+			runs, len(execs))
+	}
+	if lock.unlocked {
+		t.Errorf("expected lock that was never held not to be released")
+	}
+}
+
+func TestLockError(t *testing.T) {
+	lockErr := errors.New("lock backend unavailable")
+	lock := &testLock{err: lockErr}
+	runs := 0
+	execs := watchLocked(lock, &runs)
+	if runs != 0 {
+		t.Errorf("expected command not to run on lock error; ran %d times", runs)
+	}
+	if len(execs) == 0 {
+		t.Fatalf("expected lock errors to be reported as executions")
+	}
+	for i, exec := range execs {
+		if !errors.Is(exec.Error, lockErr) {
+			t.Errorf("expected execution %d error to wrap %v; got %v", i, lockErr, exec.Error)
+		}
+	}
+}
+
+// Lock whose backend never answers
+type hungLock struct{}
+
+func (hungLock) TryLock(ctx context.Context) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func (hungLock) Unlock() {}
+
+func TestLockHung(t *testing.T) {
+	runs := 0
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command: func(time.Time) error {
+			runs += 1
+			return nil
+		},
+		Timeout: 20 * time.Millisecond,
+		Lock:    hungLock{},
+	})
+	var exec *Execution
+	select {
+	case exec = <-w.Executions():
+	case <-time.After(1 * time.Second):
+	}
+	discard(w)
+	w.Stop()
+	if exec == nil {
+		t.Fatalf("expected a hung lock to be reported")
+	}
+	if !errors.Is(exec.Error, context.DeadlineExceeded) {
+		t.Errorf("expected the lock to time out; got %v", exec.Error)
+	}
+	if runs != 0 {
+		t.Errorf("expected command not to run without the lock; ran %d times", runs)
+	}
+
+	// Nor does a hung lock hold up Stop
+	w = Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Hour,
+		Lock:     hungLock{},
+	})
+	discard(w)
+	time.Sleep(20 * time.Millisecond)
+	stopped := make(chan bool)
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(1 * time.Second):
+		t.Errorf("expected Stop to give up on a hung lock")
+	}
+}
+
+type testLedger struct {
+	mu      sync.Mutex
+	claimed map[time.Time]bool
+	refused int
+}
+
+func (l *testLedger) Claim(_ context.Context, period time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.claimed[period] {
+		l.refused += 1
+		return false, nil
+	}
+	l.claimed[period] = true
+	return true, nil
+}
+
+func TestLedgerAtMostOncePerPeriod(t *testing.T) {
+	ledger := &testLedger{claimed: make(map[time.Time]bool)}
+	var mu sync.Mutex
+	runs := make(map[time.Time]int)
+	newTask := func() *Task {
+		return &Task{
+			Schedule: 20 * time.Millisecond,
+			Command: func(ts time.Time) error {
+				mu.Lock()
+				runs[ts.Truncate(20*time.Millisecond)] += 1
+				mu.Unlock()
+				return nil
+			},
+			Timeout: 1 * time.Second,
+			Ledger:  ledger,
+		}
+	}
+	replicas := []*Watchdog{Watch(newTask()), Watch(newTask())}
+	for _, w := range replicas {
+		discard(w)
+	}
+	<-time.After(70 * time.Millisecond)
+	for _, w := range replicas {
+		w.Stop()
+	}
+
+	if len(runs) == 0 {
+		t.Fatalf("expected some executions")
+	}
+	for period, count := range runs {
+		if count != 1 {
+			t.Errorf("expected period %v to run once across replicas; ran %d times",
+				period, count)
+		}
+	}
+	if ledger.refused == 0 {
+		t.Errorf("expected some claims to be refused to the second replica")
+	}
+}
+
+// Ledger whose store never answers
+type hungLedger struct{}
+
+func (hungLedger) Claim(ctx context.Context, _ time.Time) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestLedgerHung(t *testing.T) {
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  20 * time.Millisecond,
+		Ledger:   hungLedger{},
+	})
+	var exec *Execution
+	select {
+	case exec = <-w.Executions():
+	case <-time.After(1 * time.Second):
+	}
+	discard(w)
+	w.Stop()
+	if exec == nil {
+		t.Fatalf("expected a hung ledger to be reported")
+	}
+	if !errors.Is(exec.Error, context.DeadlineExceeded) {
+		t.Errorf("expected the claim to time out; got %v", exec.Error)
+	}
+}
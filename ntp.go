@@ -0,0 +1,114 @@
+package watchdog
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Seconds between the NTP epoch (1900) and the Unix epoch
+const ntpEpochOffset = 2208988800
+
+// CommandContext for a Task checking the local wall clock against NTP
+// servers (given as host or host:port), failing when it is off by more
+// than maxSkew. Servers are tried in order until one responds; the
+// check fails if none do. Clock skew breaks timestamps in events and
+// anything else relying on wall-clock time:
+//
+//	watchdog.Watch(&watchdog.Task{
+//		Name:           "clock-skew",
+//		Schedule:       10 * time.Minute,
+//		CommandContext: watchdog.CheckClockSkew(1*time.Second, "pool.ntp.org"),
+//		Timeout:        30 * time.Second,
+//	})
+//
+// Each server is given up to 5 seconds to respond, or less if the
+// context has an earlier deadline.
+func CheckClockSkew(maxSkew time.Duration, servers ...string) func(context.Context, time.Time) error {
+	return func(ctx context.Context, _ time.Time) error {
+		var errs []error
+		for _, server := range servers {
+			offset, err := queryNTP(ctx, server)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if offset > maxSkew || offset < -maxSkew {
+				return fmt.Errorf("watchdog: clock is off by %v from %s, more than %v",
+					offset, server, maxSkew)
+			}
+			return nil
+		}
+		if len(errs) == 0 {
+			return errors.New("watchdog: no NTP servers to check the clock against")
+		}
+		return fmt.Errorf("watchdog: checking clock skew: %w", errors.Join(errs...))
+	}
+}
+
+// Query an NTP server (SNTP, RFC 4330) for the offset of its clock
+// from the local one
+func queryNTP(ctx context.Context, server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	request := make([]byte, 48)
+	// Leap indicator 0, version 4, mode 3 (client)
+	request[0] = 0x23
+	sentAt := time.Now()
+	putNTPTime(request[40:], sentAt)
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	if err != nil {
+		return 0, err
+	}
+	receivedAt := time.Now()
+	if n < 48 {
+		return 0, fmt.Errorf("watchdog: short NTP response from %s", server)
+	}
+	if mode := response[0] & 0x7; mode != 4 {
+		return 0, fmt.Errorf("watchdog: unexpected NTP mode %d from %s", mode, server)
+	}
+	if stratum := response[1]; stratum == 0 {
+		return 0, fmt.Errorf("watchdog: NTP server %s refused the request", server)
+	}
+	// The server echoes our transmit time as the originate time
+	if string(response[24:32]) != string(request[40:48]) {
+		return 0, fmt.Errorf("watchdog: mismatched NTP response from %s", server)
+	}
+	serverReceivedAt := ntpTime(response[32:])
+	serverSentAt := ntpTime(response[40:])
+	return (serverReceivedAt.Sub(sentAt) + serverSentAt.Sub(receivedAt)) / 2, nil
+}
+
+// Decode a 64-bit NTP timestamp
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b)) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:]))
+	return time.Unix(seconds, fraction*1e9>>32)
+}
+
+// Encode a 64-bit NTP timestamp
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:], uint32((int64(t.Nanosecond())<<32)/1e9))
+}
@@ -0,0 +1,92 @@
+package watchdog
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Run a fake NTP server whose clock is off by the given offset,
+// returning its address
+func fakeNTPServer(t *testing.T, offset time.Duration) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			now := time.Now().Add(offset)
+			response := make([]byte, 48)
+			// Leap indicator 0, version 4, mode 4 (server)
+			response[0] = 0x24
+			response[1] = 1
+			copy(response[24:32], buf[40:48])
+			putNTPTime(response[32:], now)
+			putNTPTime(response[40:], now)
+			conn.WriteTo(response, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestNTPTime(t *testing.T) {
+	at := time.Date(2014, 1, 1, 12, 0, 0, 500000000, time.UTC)
+	b := make([]byte, 8)
+	putNTPTime(b, at)
+	if decoded := ntpTime(b); decoded.Sub(at).Abs() > time.Microsecond {
+		t.Errorf("expected %v; got %v", at, decoded)
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	ctx := context.Background()
+	accurate := fakeNTPServer(t, 0)
+	skewed := fakeNTPServer(t, 1*time.Minute)
+
+	if err := CheckClockSkew(1*time.Second, accurate)(ctx, time.Now()); err != nil {
+		t.Errorf("expected no error for an accurate clock; got %v", err)
+	}
+	err := CheckClockSkew(1*time.Second, skewed)(ctx, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "clock is off by") {
+		t.Errorf("expected an error for a skewed clock; got %v", err)
+	}
+	if err := CheckClockSkew(2*time.Minute, skewed)(ctx, time.Now()); err != nil {
+		t.Errorf("expected skew within the threshold to pass; got %v", err)
+	}
+
+	// Servers that refuse are skipped
+	closed, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	closed.Close()
+	if err := CheckClockSkew(1*time.Second, closed.LocalAddr().String(), accurate)(ctx, time.Now()); err != nil {
+		t.Errorf("expected to fall back to a responding server; got %v", err)
+	}
+
+	// Servers that never answer give up with the context
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer silent.Close()
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := CheckClockSkew(1*time.Second, silent.LocalAddr().String())(timeout, time.Now()); err == nil {
+		t.Errorf("expected an error once the context expired")
+	}
+	if err := CheckClockSkew(1*time.Second)(ctx, time.Now()); err == nil {
+		t.Errorf("expected an error without servers")
+	}
+}
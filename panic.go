@@ -0,0 +1,43 @@
+package watchdog
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// How a panic in a Task's Command is handled
+type PanicPolicy int
+
+const (
+	// Recover the panic and report it as the execution's Error, a
+	// *PanicError
+	RecoverPanics PanicPolicy = iota
+	// Let the panic crash the process, for embedders treating a
+	// panicking check as fatal
+	PropagatePanics
+)
+
+// Error reported for an execution whose Command panicked
+type PanicError struct {
+	// Value passed to panic
+	Value interface{}
+	// Stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("watchdog: command panicked: %v", p.Value)
+}
+
+// Call f, recovering any panic as a PanicError unless the policy is to
+// propagate it
+func guard(policy PanicPolicy, f func() error) (err error) {
+	if policy == RecoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+	}
+	return f()
+}
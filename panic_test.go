@@ -0,0 +1,42 @@
+package watchdog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command:  func(time.Time) error { panic("oh snap") },
+		Timeout:  1 * time.Second,
+	})
+	exec := <-w.Executions()
+	discard(w)
+	w.Stop()
+	var p *PanicError
+	if !errors.As(exec.Error, &p) {
+		t.Fatalf("expected a recovered panic; got %v", exec.Error)
+	}
+	if p.Value != "oh snap" {
+		t.Errorf("expected the panic value; got %v", p.Value)
+	}
+	if !strings.Contains(string(p.Stack), "TestRecoverPanics") {
+		t.Errorf("expected the stack of the panicking command; got %s", p.Stack)
+	}
+	if exec.Severity != Warning {
+		t.Errorf("expected a panic to be a failure; got %v", exec.Severity)
+	}
+}
+
+func TestPropagatePanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "oh snap" {
+			t.Errorf("expected the panic to propagate; got %v", r)
+		}
+	}()
+	guard(PropagatePanics, func() error { panic("oh snap") })
+	t.Errorf("expected guard to panic")
+}
@@ -0,0 +1,70 @@
+package watchdog
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Tasks belonging to running Watchdogs: a Task has mutable scheduling
+// state, so it may only be watched once at a time. Names need only be
+// unique within a Watchdog, so that unrelated Watchdogs in the same
+// process (e.g. an application's and a library's) may reuse them.
+var registry = struct {
+	sync.Mutex
+	tasks map[*Task]bool
+}{
+	tasks: make(map[*Task]bool),
+}
+
+// Human-readable description of a task for error messages
+func describe(task *Task) string {
+	if task.Name != "" {
+		return fmt.Sprintf("task %q", task.Name)
+	}
+	return fmt.Sprintf("task %p", task)
+}
+
+// Register the given tasks, or return an error describing the first
+// one that is already registered or repeated.
+func register(tasks []*Task) error {
+	registry.Lock()
+	defer registry.Unlock()
+	if err := checkRegistry(tasks); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		registry.tasks[task] = true
+	}
+	return nil
+}
+
+// Check whether the given tasks could be registered; the registry
+// must be locked
+func checkRegistry(tasks []*Task) error {
+	tasksSeen := make(map[*Task]bool)
+	namesSeen := make(map[string]bool)
+	for _, task := range tasks {
+		if tasksSeen[task] {
+			return fmt.Errorf("watchdog: %s passed more than once", describe(task))
+		}
+		if registry.tasks[task] {
+			return fmt.Errorf("watchdog: %s is already being watched", describe(task))
+		}
+		if name := task.Name; name != "" {
+			if namesSeen[name] {
+				return fmt.Errorf("watchdog: more than one task named %q", name)
+			}
+			namesSeen[name] = true
+		}
+		tasksSeen[task] = true
+	}
+	return nil
+}
+
+func unregister(tasks []*Task) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, task := range tasks {
+		delete(registry.tasks, task)
+	}
+}
@@ -0,0 +1,97 @@
+package watchdog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTask(name string) *Task {
+	return &Task{
+		Name:     name,
+		Schedule: 10 * time.Millisecond,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+	}
+}
+
+// Call Watch, returning the message of the error it panics with, if
+// it does
+func tryWatch(tasks ...*Task) (w *Watchdog, msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = r.(error).Error()
+		}
+	}()
+	return Watch(tasks...), ""
+}
+
+func TestDuplicateRegistration(t *testing.T) {
+	shared := newTestTask("")
+	running := Watch(shared, newTestTask("shared-name"))
+	discard(running)
+
+	for _, tc := range []struct {
+		description string
+		tasks       []*Task
+		expected    string
+	}{
+		{"same name twice", []*Task{newTestTask("a"), newTestTask("b"), newTestTask("a")},
+			`more than one task named "a"`},
+		{"task in running watchdog", []*Task{shared}, "already being watched"},
+	} {
+		w, msg := tryWatch(tc.tasks...)
+		if w != nil {
+			w.Stop()
+		}
+		if !strings.Contains(msg, tc.expected) {
+			t.Errorf("%s: expected panic containing %q; got %q", tc.description, tc.expected, msg)
+		}
+	}
+
+	// Names are only unique within a Watchdog
+	w, msg := tryWatch(newTestTask("shared-name"))
+	if msg != "" {
+		t.Errorf("expected another Watchdog to reuse a name; got %q", msg)
+	} else {
+		discard(w)
+		w.Stop()
+	}
+
+	task := newTestTask("")
+	if _, msg := tryWatch(task, task); !strings.Contains(msg, "passed more than once") {
+		t.Errorf("expected panic for the same task passed twice; got %q", msg)
+	}
+
+	running.Stop()
+	w, msg = tryWatch(shared, newTestTask("shared-name"))
+	if msg != "" {
+		t.Errorf("expected tasks to be reusable after Stop; got %q", msg)
+	} else {
+		discard(w)
+		w.Stop()
+	}
+}
+
+func TestNew(t *testing.T) {
+	task := newTestTask("")
+	if _, err := New(task, task); err == nil || !strings.Contains(err.Error(), "passed more than once") {
+		t.Errorf("expected an error for the same task passed twice; got %v", err)
+	}
+	w, err := New(task)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	discard(w)
+	w.Stop()
+
+	// Simulate panics with the error too
+	func() {
+		defer func() {
+			if err, ok := recover().(error); !ok || !strings.Contains(err.Error(), "passed more than once") {
+				t.Errorf("expected Simulate to panic with an error; got %v", err)
+			}
+		}()
+		Simulate(simStart, task, task)
+	}()
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package watchdog
+
+import (
+	"time"
+)
+
+// Shortest Schedule accepted on the wall clock: other platforms
+// generally time sleeps to within microseconds, but a millisecond
+// leaves room for virtual machines and busy hosts
+const scheduleFloor = 1 * time.Millisecond
@@ -0,0 +1,9 @@
+package watchdog
+
+import (
+	"time"
+)
+
+// Shortest Schedule accepted on the wall clock: the default Windows
+// timer resolution, which may apply whatever Go's own timers do
+const scheduleFloor = 15625 * time.Microsecond
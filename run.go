@@ -0,0 +1,25 @@
+package watchdog
+
+import (
+	"context"
+)
+
+// Block until the context is done, then Stop the Watchdog and return,
+// for use with errgroup.Group or run-group style lifecycle managers:
+//
+//	w := watchdog.Watch(tasks...)
+//	g.Go(func() error { return w.Run(ctx) })
+//
+// The Watchdog starts running as soon as it is created; Run ties its
+// lifetime to the context. Run also returns if the Watchdog is
+// stopped by other means. A Watchdog has no fatal internal errors, so
+// Run returns nil once stopped: a cancelled context is a normal
+// shutdown, not a failure.
+func (w *Watchdog) Run(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+	case <-w.done:
+	}
+	w.Stop()
+	return nil
+}
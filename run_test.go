@@ -0,0 +1,53 @@
+package watchdog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunStopsOnCancel(t *testing.T) {
+	w := Watch(newTestTask(""))
+	discard(w)
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- w.Run(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		t.Fatalf("expected Run to block until cancelled; returned %v", err)
+	case <-time.After(30 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("expected clean shutdown; got %v", err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("expected Run to return after cancellation")
+	}
+	if _, ok := <-w.Stalls(); ok {
+		t.Errorf("expected Watchdog to be stopped after Run returns")
+	}
+}
+
+func TestRunReturnsOnStop(t *testing.T) {
+	w := Watch(newTestTask(""))
+	discard(w)
+	result := make(chan error)
+	go func() {
+		result <- w.Run(context.Background())
+	}()
+	w.Stop()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("expected clean shutdown; got %v", err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("expected Run to return after Stop")
+	}
+}
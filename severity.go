@@ -0,0 +1,65 @@
+package watchdog
+
+import (
+	"time"
+)
+
+// How serious an event is, for routing and filtering
+type Severity int
+
+const (
+	// Nothing wrong: a successful, timely execution
+	Info Severity = iota
+	// Something to look at: a failure, a slow execution, or a
+	// stall of a non-critical Task
+	Warning
+	// Something to act on: a failure or stall of a critical Task,
+	// or repeated failures past a Task's escalation level
+	Critical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// Severity of an execution of a task that has now failed the given
+// number of times in a row (including this execution)
+func executionSeverity(config *Task, e *Execution, failures int) Severity {
+	if e.Error == nil {
+		if config.WarnAfter > 0 && e.FinishedAt.Sub(e.DispatchedAt) > config.WarnAfter {
+			return Warning
+		}
+		if config.MaxDeferral > 0 && e.Deferred > config.MaxDeferral {
+			return Warning
+		}
+		return Info
+	}
+	if config.Critical || (config.EscalateAfter > 0 && failures >= config.EscalateAfter) {
+		return Critical
+	}
+	return Warning
+}
+
+func stallSeverity(config *Task) Severity {
+	if config.Critical {
+		return Critical
+	}
+	return Warning
+}
+
+// Severity of a wall-clock jump: only large jumps are likely to
+// disturb anything relying on wall-clock timestamps
+func clockJumpSeverity(offset time.Duration) Severity {
+	if offset >= 1*time.Minute || offset <= -1*time.Minute {
+		return Warning
+	}
+	return Info
+}
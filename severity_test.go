@@ -0,0 +1,87 @@
+package watchdog
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecutionSeverity(t *testing.T) {
+	start := time.Date(2014, 1, 1, 12, 0, 0, 0, time.UTC)
+	fail := errors.New("oh snap")
+	for i, tc := range []struct {
+		config   Task
+		duration time.Duration
+		err      error
+		failures int
+		expected Severity
+	}{
+		{Task{}, 1 * time.Second, nil, 0, Info},
+		{Task{WarnAfter: 2 * time.Second}, 1 * time.Second, nil, 0, Info},
+		{Task{WarnAfter: 2 * time.Second}, 3 * time.Second, nil, 0, Warning},
+		{Task{}, 1 * time.Second, fail, 1, Warning},
+		{Task{}, 1 * time.Second, fail, 10, Warning},
+		{Task{EscalateAfter: 3}, 1 * time.Second, fail, 2, Warning},
+		{Task{EscalateAfter: 3}, 1 * time.Second, fail, 3, Critical},
+		{Task{Critical: true}, 1 * time.Second, fail, 1, Critical},
+		{Task{Critical: true}, 1 * time.Second, nil, 0, Info},
+	} {
+		exec := &Execution{
+			StartedAt:    start,
+			DispatchedAt: start,
+			FinishedAt:   start.Add(tc.duration),
+			Error:        tc.err,
+		}
+		if severity := executionSeverity(&tc.config, exec, tc.failures); severity != tc.expected {
+			t.Errorf("case %d: expected %v; got %v", i, tc.expected, severity)
+		}
+	}
+	deferred := &Execution{StartedAt: start, DispatchedAt: start, FinishedAt: start,
+		Deferred: 2 * time.Minute}
+	if severity := executionSeverity(&Task{MaxDeferral: 1 * time.Minute}, deferred, 0); severity != Warning {
+		t.Errorf("expected long deferrals to be %v; got %v", Warning, severity)
+	}
+}
+
+func TestStallSeverity(t *testing.T) {
+	if severity := stallSeverity(&Task{}); severity != Warning {
+		t.Errorf("expected stalls to be %v by default; got %v", Warning, severity)
+	}
+	if severity := stallSeverity(&Task{Critical: true}); severity != Critical {
+		t.Errorf("expected critical task stalls to be %v; got %v", Critical, severity)
+	}
+}
+
+func TestSeverityEscalation(t *testing.T) {
+	w := Watch(&Task{
+		Schedule:      10 * time.Millisecond,
+		Command:       func(time.Time) error { return errors.New("oh snap") },
+		Timeout:       1 * time.Second,
+		EscalateAfter: 2,
+	})
+	var severities []Severity
+	done := make(chan bool)
+	go func() {
+		for e := range w.Executions() {
+			severities = append(severities, e.Severity)
+		}
+		done <- true
+	}()
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+	<-time.After(45 * time.Millisecond)
+	w.Stop()
+	<-done
+
+	if len(severities) < 3 {
+		t.Fatalf("expected at least 3 executions; got %d", len(severities))
+	}
+	expected := []Severity{Warning, Critical, Critical}
+	for i, severity := range expected {
+		if severities[i] != severity {
+			t.Errorf("execution %d: expected %v; got %v", i, severity, severities[i])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package watchdog
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Returned by RunUntilSignal when the Watchdog could not be stopped
+// cleanly: either a second signal arrived or the drain deadline
+// passed while tasks were still executing.
+var ErrForcedShutdown = errors.New("watchdog: forced shutdown before tasks completed")
+
+// Block until one of the given signals is received (os.Interrupt if
+// none are given), then Stop the Watchdog. Stop waits for any
+// currently-executing tasks; RunUntilSignal stops waiting and returns
+// ErrForcedShutdown if a second signal arrives, or if the longest Task
+// Timeout elapses first (if any Task has one), so that the caller can
+// exit regardless. Use RunUntilSignalWith to choose otherwise.
+//
+// As with Stop, the Executions and Stalls channels must still be
+// drained (typically in another goroutine) for the shutdown to
+// complete.
+func RunUntilSignal(w *Watchdog, sigs ...os.Signal) error {
+	return RunUntilSignalWith(w, Shutdown{
+		Signals:             sigs,
+		DrainTimeout:        w.drainTimeout(),
+		ForceOnSecondSignal: true,
+	})
+}
+
+// Settings for RunUntilSignalWith
+type Shutdown struct {
+	// Signals to stop on; os.Interrupt if empty
+	Signals []os.Signal
+	// How long to wait for Stop before returning ErrForcedShutdown;
+	// zero to wait for as long as it takes
+	DrainTimeout time.Duration
+	// Whether a second signal returns ErrForcedShutdown at once
+	ForceOnSecondSignal bool
+}
+
+// Like RunUntilSignal, with the given settings
+func RunUntilSignalWith(w *Watchdog, s Shutdown) error {
+	sigs := s.Signals
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt}
+	}
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, sigs...)
+	defer signal.Stop(signals)
+	return runUntil(w, signals, s)
+}
+
+func runUntil(w *Watchdog, signals <-chan os.Signal, s Shutdown) error {
+	<-signals
+	stopped := make(chan bool)
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+	// Receiving from nil channels blocks forever
+	var forced <-chan os.Signal
+	if s.ForceOnSecondSignal {
+		forced = signals
+	}
+	var expired <-chan time.Time
+	if s.DrainTimeout > 0 {
+		deadline := time.NewTimer(s.DrainTimeout)
+		defer deadline.Stop()
+		expired = deadline.C
+	}
+	select {
+	case <-stopped:
+		return nil
+	case <-forced:
+		return ErrForcedShutdown
+	case <-expired:
+		return ErrForcedShutdown
+	}
+}
+
+// Longest time a clean Stop may reasonably take: past its Timeout, an
+// execution is stalled and not worth waiting for.
+func (w *Watchdog) drainTimeout() time.Duration {
+	var timeout time.Duration
+	for _, config := range w.configs {
+		if config.Timeout > timeout {
+			timeout = config.Timeout
+		}
+	}
+	return timeout
+}
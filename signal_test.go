@@ -0,0 +1,135 @@
+package watchdog
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func discard(w *Watchdog) {
+	go func() {
+		for range w.Executions() {
+		}
+	}()
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+}
+
+// Settings RunUntilSignal uses
+func defaults(w *Watchdog) Shutdown {
+	return Shutdown{DrainTimeout: w.drainTimeout(), ForceOnSecondSignal: true}
+}
+
+func TestRunUntilSignalStops(t *testing.T) {
+	w := Watch(&Task{
+		Schedule: 50 * time.Millisecond,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+	})
+	discard(w)
+
+	signals := make(chan os.Signal, 1)
+	signals <- os.Interrupt
+	if err := runUntil(w, signals, defaults(w)); err != nil {
+		t.Errorf("expected clean stop; got %v", err)
+	}
+}
+
+func TestRunUntilSignalForced(t *testing.T) {
+	release := make(chan bool)
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command: func(time.Time) error {
+			<-release
+			return nil
+		},
+		Timeout: 1 * time.Hour,
+	})
+	defer close(release)
+	discard(w)
+	// Let the first execution start before asking to stop
+	<-time.After(30 * time.Millisecond)
+
+	signals := make(chan os.Signal, 2)
+	signals <- os.Interrupt
+	signals <- os.Interrupt
+	if err := runUntil(w, signals, defaults(w)); err != ErrForcedShutdown {
+		t.Errorf("expected %v on second signal; got %v", ErrForcedShutdown, err)
+	}
+}
+
+func TestRunUntilSignalDeadline(t *testing.T) {
+	release := make(chan bool)
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command: func(time.Time) error {
+			<-release
+			return nil
+		},
+		Timeout: 20 * time.Millisecond,
+	})
+	defer close(release)
+	discard(w)
+	<-time.After(30 * time.Millisecond)
+
+	signals := make(chan os.Signal, 1)
+	signals <- os.Interrupt
+	start := time.Now()
+	if err := runUntil(w, signals, defaults(w)); err != ErrForcedShutdown {
+		t.Errorf("expected %v after drain deadline; got %v", ErrForcedShutdown, err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("expected to give up after the task timeout; waited %v", elapsed)
+	}
+}
+
+func TestRunUntilSignalWith(t *testing.T) {
+	release := make(chan bool)
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command: func(time.Time) error {
+			<-release
+			return nil
+		},
+		Timeout: 1 * time.Millisecond,
+	})
+	discard(w)
+	<-time.After(30 * time.Millisecond)
+
+	signals := make(chan os.Signal, 2)
+	signals <- os.Interrupt
+	signals <- os.Interrupt
+	result := make(chan error)
+	go func() {
+		result <- runUntil(w, signals, Shutdown{DrainTimeout: 1 * time.Second})
+	}()
+	select {
+	case err := <-result:
+		t.Fatalf("expected to keep waiting despite the second signal and short Timeout; got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Errorf("expected clean stop; got %v", err)
+	}
+}
+
+func TestRunUntilSignalNoTimeout(t *testing.T) {
+	w := Watch(&Task{
+		Schedule: 10 * time.Millisecond,
+		Command: func(time.Time) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		},
+	})
+	discard(w)
+	<-time.After(15 * time.Millisecond)
+
+	signals := make(chan os.Signal, 1)
+	signals <- os.Interrupt
+	if err := runUntil(w, signals, defaults(w)); err != nil {
+		t.Errorf("expected to wait indefinitely without Timeouts; got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package watchdog
+
+import (
+	"sync"
+	"time"
+)
+
+// Create a new watchdog with the given task(s), driven by simulated
+// time starting at the given time rather than by the wall clock. Time
+// only passes when Advance is called: tasks are scheduled, and
+// executions are timed and considered stalled, in simulated time. This
+// suits game loops and discrete-event simulations.
+//
+// Commands still run in their own goroutines, so Advance returns
+// without waiting for them; a Command that returns before time is
+// advanced again is considered to have taken no time at all.
+func Simulate(start time.Time, tasks ...*Task) *Watchdog {
+	w, err := watch(&simClock{current: start}, tasks)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// Advance simulated time by the given duration, firing any ticks and
+// stall timeouts that fall due. As with time.Ticker, a tick falling
+// due while the previous one has not been picked up is dropped, so
+// advance in steps no larger than the shortest Schedule to observe
+// every tick. Panics if the Watchdog was not created by Simulate.
+func (w *Watchdog) Advance(d time.Duration) {
+	sim, ok := w.clock.(*simClock)
+	if !ok {
+		panic("watchdog: Advance called on a Watchdog not created by Simulate")
+	}
+	sim.advance(d)
+}
+
+func (w *Watchdog) simulated() bool {
+	_, ok := w.clock.(*simClock)
+	return ok
+}
+
+// A clock that only moves when advanced
+type simClock struct {
+	sync.Mutex
+	current time.Time
+	// Active timers only, so that fired and stopped ones can be
+	// garbage collected
+	timers []*simTimer
+}
+
+// A simulated ticker (if period is non-zero) or timer
+type simTimer struct {
+	clock  *simClock
+	c      chan time.Time
+	period time.Duration
+	when   time.Time
+	active bool
+}
+
+func (c *simClock) now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.current
+}
+
+func (c *simClock) newTicker(d time.Duration) timer {
+	return c.add(d, d)
+}
+
+func (c *simClock) newTimer(d time.Duration) timer {
+	return c.add(d, 0)
+}
+
+// Simulated timers fire exactly when due
+func (c *simClock) resolution() time.Duration {
+	return 0
+}
+
+func (c *simClock) minSchedule() time.Duration {
+	return 0
+}
+
+func (c *simClock) add(d, period time.Duration) *simTimer {
+	c.Lock()
+	defer c.Unlock()
+	t := &simTimer{
+		clock:  c,
+		c:      make(chan time.Time, 1),
+		period: period,
+		when:   c.current.Add(d),
+		active: true,
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (c *simClock) advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	end := c.current.Add(d)
+	for {
+		// Fire timers one at a time in order, so that each one
+		// sees the time it fell due at
+		var next *simTimer
+		for _, t := range c.timers {
+			if t.active && !t.when.After(end) && (next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		c.current = next.when
+		select {
+		case next.c <- next.when:
+		default:
+		}
+		if next.period > 0 {
+			next.when = next.when.Add(next.period)
+		} else {
+			c.deactivate(next)
+		}
+	}
+	c.current = end
+}
+
+// Remove an active timer; the clock must be locked
+func (c *simClock) deactivate(t *simTimer) {
+	t.active = false
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *simTimer) channel() <-chan time.Time {
+	return t.c
+}
+
+func (t *simTimer) reset(d time.Duration) {
+	t.clock.Lock()
+	defer t.clock.Unlock()
+	t.drain()
+	t.when = t.clock.current.Add(d)
+	if !t.active {
+		t.active = true
+		t.clock.timers = append(t.clock.timers, t)
+	}
+}
+
+func (t *simTimer) stop() {
+	t.clock.Lock()
+	defer t.clock.Unlock()
+	t.drain()
+	if t.active {
+		t.clock.deactivate(t)
+	}
+}
+
+// Discard any undelivered firing, as time.Timer does on Reset and Stop
+func (t *simTimer) drain() {
+	select {
+	case <-t.c:
+	default:
+	}
+}
@@ -0,0 +1,121 @@
+package watchdog
+
+import (
+	"testing"
+	"time"
+)
+
+var simStart = time.Date(2014, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestSimulatedScheduling(t *testing.T) {
+	w := Simulate(simStart, &Task{
+		Schedule: 1 * time.Second,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+	})
+	defer w.Stop()
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+
+	for i := 1; i <= 3; i++ {
+		w.Advance(1 * time.Second)
+		select {
+		case exec := <-w.Executions():
+			expected := simStart.Add(time.Duration(i) * time.Second)
+			if !exec.StartedAt.Equal(expected) {
+				t.Errorf("execution %d: expected start at %v; got %v", i, expected, exec.StartedAt)
+			}
+			if !exec.FinishedAt.Equal(expected) {
+				t.Errorf("execution %d: expected no simulated time to pass; finished at %v",
+					i, exec.FinishedAt)
+			}
+		case <-time.After(1 * time.Second):
+			t.Fatalf("execution %d: expected an execution after advancing", i)
+		}
+	}
+	select {
+	case exec := <-w.Executions():
+		t.Errorf("expected no executions without advancing; got %v", exec)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSimulatedStall(t *testing.T) {
+	started := make(chan bool, 1)
+	release := make(chan bool)
+	w := Simulate(simStart, &Task{
+		Schedule: 1 * time.Second,
+		Command: func(time.Time) error {
+			select {
+			case started <- true:
+			default:
+			}
+			<-release
+			return nil
+		},
+		Timeout: 1500 * time.Millisecond,
+	})
+
+	w.Advance(1 * time.Second)
+	<-started
+	w.Advance(1 * time.Second)
+	select {
+	case stall := <-w.Stalls():
+		t.Fatalf("expected no stall before the timeout; got stall at %v", stall.StalledAt)
+	case <-time.After(50 * time.Millisecond):
+	}
+	w.Advance(1 * time.Second)
+	select {
+	case stall := <-w.Stalls():
+		if expected := simStart.Add(2500 * time.Millisecond); !stall.StalledAt.Equal(expected) {
+			t.Errorf("expected stall at %v; got %v", expected, stall.StalledAt)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("expected a stall once simulated time passed the timeout")
+	}
+	release <- true
+	exec := <-w.Executions()
+	if expected := simStart.Add(3 * time.Second); !exec.FinishedAt.Equal(expected) {
+		t.Errorf("expected execution to finish at %v; got %v", expected, exec.FinishedAt)
+	}
+
+	go func() {
+		for range w.Executions() {
+		}
+	}()
+	close(release)
+	w.Stop()
+}
+
+func TestAdvanceWallClock(t *testing.T) {
+	w := Watch(newTestTask(""))
+	discard(w)
+	defer w.Stop()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Advance to panic on a wall-clock Watchdog")
+		}
+	}()
+	w.Advance(1 * time.Second)
+}
+
+func TestSimulatedTimersReleased(t *testing.T) {
+	c := &simClock{current: simStart}
+	ticker := c.newTicker(1 * time.Second)
+	fired, stopped := c.newTimer(1*time.Second), c.newTimer(1*time.Hour)
+	stopped.stop()
+	c.advance(2 * time.Second)
+	if len(c.timers) != 1 || c.timers[0] != ticker {
+		t.Errorf("expected only the ticker to be kept; got %d timers", len(c.timers))
+	}
+	fired.reset(1 * time.Second)
+	c.advance(1 * time.Second)
+	if at := <-fired.channel(); !at.Equal(simStart.Add(3 * time.Second)) {
+		t.Errorf("expected a reset timer to fire again; fired at %v", at)
+	}
+	if len(c.timers) != 1 {
+		t.Errorf("expected the reset timer to be released again; got %d timers", len(c.timers))
+	}
+}
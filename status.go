@@ -0,0 +1,101 @@
+package watchdog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Point-in-time view of a Watchdog's internal state
+type Snapshot struct {
+	// Time the snapshot was taken
+	TakenAt time.Time
+	// Status of each task, in the order they were passed to Watch
+	Tasks []TaskStatus
+	// Events sent but not yet received from the Executions and
+	// Stalls channels
+	ExecutionsBacklog int
+	StallsBacklog     int
+	// Events dropped because the ClockJumps, Resumes and Deferrals
+	// channels were full
+	DroppedClockJumps uint64
+	DroppedResumes    uint64
+	DroppedDeferrals  uint64
+	// Timer resolution detected on the host by timing a few short
+	// sleeps (zero for simulated time): an upper bound, within which
+	// Timeouts and durations are accurate
+	ClockResolution time.Duration
+}
+
+// Point-in-time view of a single task
+type TaskStatus struct {
+	// Task being watched
+	Task *Task
+	// Settings copied when the Task was registered
+	Schedule time.Duration
+	Timeout  time.Duration
+	Weight   float64
+	Critical bool
+	// Whether an execution is in flight and, if so, the time it
+	// was originally scheduled for and the time it was dispatched
+	Running      bool
+	StartedAt    time.Time
+	DispatchedAt time.Time
+	// Whether the execution in flight has stalled
+	Stalled bool
+	// Whether a tick is queued behind the execution in flight
+	Queued bool
+	// Totals since the Watchdog started
+	Executions uint64
+	Failures   uint64
+	Stalls     uint64
+	// Ticks dropped because another was already queued
+	DroppedTicks uint64
+	// Most recent execution, if any
+	Last *Execution
+}
+
+// Status of a task, updated by its goroutines. Kept apart from the
+// scheduler's own state so that taking a snapshot never waits on a
+// slow consumer.
+type taskStatus struct {
+	sync.Mutex
+	status TaskStatus
+	// Ticks queued for the task's executor
+	schedule chan tick
+	// Closed on the first success of a gate Task
+	gate chan bool
+}
+
+func (t *taskStatus) update(f func(*TaskStatus)) {
+	t.Lock()
+	defer t.Unlock()
+	f(&t.status)
+}
+
+func (t *taskStatus) get() TaskStatus {
+	t.Lock()
+	defer t.Unlock()
+	status := t.status
+	status.Queued = len(t.schedule) > 0
+	return status
+}
+
+// Take a snapshot of the Watchdog's state. Safe to call at any time,
+// including after Stop.
+func (w *Watchdog) Snapshot() Snapshot {
+	s := Snapshot{
+		TakenAt:           w.clock.now(),
+		Tasks:             make([]TaskStatus, len(w.statuses)),
+		ExecutionsBacklog: len(w.executions),
+		StallsBacklog:     len(w.stalls),
+		DroppedClockJumps: atomic.LoadUint64(&w.droppedClockJumps),
+		DroppedResumes:    atomic.LoadUint64(&w.droppedResumes),
+		DroppedDeferrals:  atomic.LoadUint64(&w.droppedDeferrals),
+		ClockResolution:   w.clock.resolution(),
+	}
+	for i, status := range w.statuses {
+		s.Tasks[i] = status.get()
+	}
+	return s
+}
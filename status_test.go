@@ -0,0 +1,92 @@
+package watchdog
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	started := make(chan bool, 1)
+	release := make(chan bool)
+	fail := errors.New("oh snap")
+	executions := 0
+	w := Simulate(simStart, &Task{
+		Name:     "blocking",
+		Schedule: 1 * time.Second,
+		Command: func(time.Time) error {
+			executions += 1
+			if executions == 1 {
+				return fail
+			}
+			started <- true
+			<-release
+			return nil
+		},
+		Timeout: 1500 * time.Millisecond,
+	})
+	w.Advance(1 * time.Second)
+	<-w.Executions()
+	w.Advance(1 * time.Second)
+	<-started
+	w.Advance(2 * time.Second)
+	<-w.Stalls()
+
+	s := w.Snapshot()
+	if expected := simStart.Add(4 * time.Second); !s.TakenAt.Equal(expected) {
+		t.Errorf("expected snapshot at %v; got %v", expected, s.TakenAt)
+	}
+	if len(s.Tasks) != 1 {
+		t.Fatalf("expected 1 task; got %d", len(s.Tasks))
+	}
+	status := s.Tasks[0]
+	if !status.Running || !status.Stalled {
+		t.Errorf("expected execution in flight to be running and stalled; got running %v, stalled %v",
+			status.Running, status.Stalled)
+	}
+	if expected := simStart.Add(2 * time.Second); !status.StartedAt.Equal(expected) {
+		t.Errorf("expected execution in flight to have started at %v; got %v",
+			expected, status.StartedAt)
+	}
+	if !status.Queued {
+		t.Errorf("expected a tick to be queued behind the stalled execution")
+	}
+	if status.Executions != 1 || status.Failures != 1 || status.Stalls != 1 {
+		t.Errorf("expected 1 execution, 1 failure, 1 stall; got %d, %d, %d",
+			status.Executions, status.Failures, status.Stalls)
+	}
+	if status.Last == nil || status.Last.Error != fail {
+		t.Errorf("expected last execution to have failed with %v; got %v", fail, status.Last)
+	}
+
+	close(release)
+	discard(w)
+	w.Stop()
+	if s := w.Snapshot(); s.Tasks[0].Running {
+		t.Errorf("expected nothing in flight after Stop")
+	}
+}
+
+func TestSnapshotLastIsCopied(t *testing.T) {
+	w := Watch(newTestTask(""))
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for e := range w.Executions() {
+			// Consumers own the executions they receive
+			e.Seq = 0
+		}
+	}()
+	var last uint64
+	for i := 0; i < 20; i++ {
+		if l := w.Snapshot().Tasks[0].Last; l != nil {
+			if l.Seq == 0 || l.Seq < last {
+				t.Errorf("expected increasing sequence numbers in snapshots; got %d after %d", l.Seq, last)
+			}
+			last = l.Seq
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	w.Stop()
+	<-done
+}
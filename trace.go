@@ -0,0 +1,43 @@
+package watchdog
+
+import (
+	"context"
+	"runtime/trace"
+	"time"
+)
+
+// runtime/trace annotations for a single execution of a Task with
+// Trace set; a nil *executionTrace annotates nothing
+type executionTrace struct {
+	ctx  context.Context
+	task *trace.Task
+}
+
+// Start a trace task for an execution, labelled with the Task and
+// the time it was scheduled for
+func startTrace(task *Task, scheduledAt time.Time) *executionTrace {
+	ctx, t := trace.NewTask(context.Background(), "watchdog.execution")
+	trace.Log(ctx, "task", describe(task))
+	trace.Log(ctx, "scheduled", scheduledAt.String())
+	return &executionTrace{ctx: ctx, task: t}
+}
+
+// Run f within a named region of the execution
+func (e *executionTrace) region(name string, f func()) {
+	if e == nil {
+		f()
+		return
+	}
+	trace.WithRegion(e.ctx, name, f)
+}
+
+// End the execution's trace task, logging its error, if any
+func (e *executionTrace) end(err error) {
+	if e == nil {
+		return
+	}
+	if err != nil {
+		trace.Log(e.ctx, "error", err.Error())
+	}
+	e.task.End()
+}
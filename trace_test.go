@@ -0,0 +1,35 @@
+package watchdog
+
+import (
+	"bytes"
+	"runtime/trace"
+	"testing"
+	"time"
+)
+
+func TestTrace(t *testing.T) {
+	if trace.IsEnabled() {
+		t.Skip("tracing already enabled")
+	}
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Fatalf("starting trace: %v", err)
+	}
+	w := Watch(&Task{
+		Name:     "traced",
+		Schedule: 10 * time.Millisecond,
+		Command:  func(time.Time) error { return nil },
+		Timeout:  1 * time.Second,
+		Trace:    true,
+	})
+	<-w.Executions()
+	discard(w)
+	w.Stop()
+	trace.Stop()
+
+	for _, expected := range []string{"watchdog.execution", "traced", "command"} {
+		if !bytes.Contains(buf.Bytes(), []byte(expected)) {
+			t.Errorf("expected %q in trace output", expected)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package watchdog
+
+import (
+	"fmt"
+	"time"
+)
+
+// Check the given tasks for the configuration errors Watch would
+// panic on, without watching them: for failing fast, e.g. in a
+// --check mode or a test. Returns an error describing the first
+// problem found.
+func Validate(tasks ...*Task) error {
+	if err := validate(wallClock{}, tasks); err != nil {
+		return err
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	return checkRegistry(tasks)
+}
+
+func validate(clock clock, tasks []*Task) error {
+	for _, task := range tasks {
+		if task.Command == nil && task.CommandContext == nil {
+			return fmt.Errorf("watchdog: %s has no Command", describe(task))
+		}
+		if task.Schedule <= 0 {
+			return fmt.Errorf("watchdog: %s schedule %v is not positive", describe(task), task.Schedule)
+		}
+		// A Schedule the clock cannot keep to would silently drift
+		if floor := clock.minSchedule(); task.Schedule < floor {
+			return fmt.Errorf("watchdog: %s schedule %v is shorter than the timer resolution %v",
+				describe(task), task.Schedule, floor)
+		}
+		if err := validateWindow(task.Window); err != nil {
+			return fmt.Errorf("watchdog: %s: %w", describe(task), err)
+		}
+		if task.GateTimeout > 0 && !task.Gate {
+			return fmt.Errorf("watchdog: %s has a GateTimeout but is not a Gate", describe(task))
+		}
+	}
+	return nil
+}
+
+func validateWindow(window Window) error {
+	switch p := window.(type) {
+	case Periodic:
+		return p.validate()
+	case *Periodic:
+		return p.validate()
+	}
+	return nil
+}
+
+// Times the given task would be scheduled for within the given span
+// after start, as if watched from then, for previewing a
+// configuration. Times outside the Task Calendar are omitted; a Window
+// may defer executions past the times listed.
+func Preview(task *Task, start time.Time, span time.Duration) []time.Time {
+	var times []time.Time
+	if task.Schedule <= 0 {
+		return times
+	}
+	for at := start.Add(task.Schedule); !at.After(start.Add(span)); at = at.Add(task.Schedule) {
+		if task.Calendar == nil || task.Calendar.Contains(at) {
+			times = append(times, at)
+		}
+	}
+	return times
+}
@@ -0,0 +1,70 @@
+package watchdog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	watched := newTestTask("validate-watched")
+	w := Watch(watched)
+	discard(w)
+	defer w.Stop()
+
+	noCommand := newTestTask("")
+	noCommand.Command = nil
+	noSchedule := newTestTask("")
+	noSchedule.Schedule = 0
+	notGate := newTestTask("")
+	notGate.GateTimeout = 1 * time.Second
+	emptyWindow := newTestTask("")
+	emptyWindow.Window = Periodic{Period: 1 * time.Hour, Open: 5 * time.Minute, Close: 5 * time.Minute}
+	oversizedWindow := newTestTask("")
+	oversizedWindow.Window = &Periodic{Period: 1 * time.Hour, Close: 2 * time.Hour}
+	noPeriod := newTestTask("")
+	noPeriod.Window = Periodic{Close: 5 * time.Minute}
+	ok := newTestTask("")
+	for i, tc := range []struct {
+		tasks    []*Task
+		expected string
+	}{
+		{[]*Task{ok}, ""},
+		{[]*Task{ok, noCommand}, "has no Command"},
+		{[]*Task{noSchedule}, "is not positive"},
+		{[]*Task{notGate}, "is not a Gate"},
+		{[]*Task{emptyWindow}, "does not fit within"},
+		{[]*Task{oversizedWindow}, "does not fit within"},
+		{[]*Task{noPeriod}, "is not positive"},
+		{[]*Task{ok, ok}, "passed more than once"},
+		{[]*Task{watched}, "already being watched"},
+	} {
+		err := Validate(tc.tasks...)
+		if tc.expected == "" && err != nil {
+			t.Errorf("case %d: expected no error; got %v", i, err)
+		}
+		if tc.expected != "" && (err == nil || !strings.Contains(err.Error(), tc.expected)) {
+			t.Errorf("case %d: expected error containing %q; got %v", i, tc.expected, err)
+		}
+	}
+	// Validating does not register anything
+	if err := Validate(ok); err != nil {
+		t.Errorf("expected a validated task to remain valid; got %v", err)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	task := newTestTask("")
+	task.Schedule = 1 * time.Second
+	task.Calendar = calendarFunc(func(at time.Time) bool { return at.Second() != 2 })
+	times := Preview(task, simStart, 4*time.Second)
+	expected := []time.Time{simStart.Add(1 * time.Second), simStart.Add(3 * time.Second), simStart.Add(4 * time.Second)}
+	if len(times) != len(expected) {
+		t.Fatalf("expected %v; got %v", expected, times)
+	}
+	for i := range times {
+		if !times[i].Equal(expected[i]) {
+			t.Errorf("execution %d: expected %v; got %v", i, expected[i], times[i])
+		}
+	}
+}
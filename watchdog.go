@@ -1,20 +1,84 @@
 package watchdog
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"time"
 )
 
-// Basic scheduling unit
+// Basic scheduling unit. A Task's fields are copied when it is passed
+// to Watch: changing them afterwards has no effect on the running
+// Watchdog (and is not safe while it runs, since the Task is also
+// reported on each Execution and Stall).
 type Task struct {
+	// Optional name: must be unique within its Watchdog
+	Name string
 	// How frequently the task should execute
 	Schedule time.Duration
 	// Function to invoke: each execution will be passed the time
 	// it was originally scheduled for (which may be behind
 	// wall-clock time in case of stalls).
 	Command func(time.Time) error
+	// Alternative to Command taking a context, which is cancelled
+	// once the execution stalls, reaches the Task's next scheduled
+	// time, or the Watchdog stops, with the CancelReason as its
+	// cause (see context.Cause and Budget). Stalls do not cancel it
+	// if the Task has no Timeout.
+	CommandContext func(context.Context, time.Time) error
+	// How panics in the Command are handled: by default, they are
+	// recovered and reported as the execution's Error
+	Panics PanicPolicy
 	// How long to wait before considering an execution stalled
 	Timeout time.Duration
+	// Successful executions taking longer than this are reported
+	// with Warning severity (zero to disable)
+	WarnAfter time.Duration
+	// Failures are reported with Critical severity once the Task
+	// has failed this many times in a row (zero to disable)
+	EscalateAfter int
+	// Whether all failures and stalls of the Task are Critical,
+	// and make the Watchdog's overall Health critical
+	Critical bool
+	// Relative importance of the Task in the Watchdog's overall
+	// Health (zero is treated as 1)
+	Weight float64
+	// Optional key for mutual exclusion: Tasks in the same Watchdog
+	// sharing a key never execute at the same time, and wait their
+	// turn instead
+	ConcurrencyKey string
+	// Optional calendar restricting when the Task runs: executions
+	// scheduled for times outside it are skipped
+	Calendar Calendar
+	// Optional window restricting when the Task executes:
+	// executions falling due outside it wait for it to open
+	Window Window
+	// Executions deferred by the Window for longer than this are
+	// reported on the Deferrals channel once they exceed it, and
+	// their Execution with Warning severity (zero to disable)
+	MaxDeferral time.Duration
+	// Optional lock shared with other Watchdog instances: when
+	// set, the Task only executes on the instance holding it
+	Lock Lock
+	// Optional record of periods already run, shared with other
+	// Watchdog instances: when set, each period of the Schedule
+	// executes at most once across all of them
+	Ledger Ledger
+	// Whether the Task is a startup gate: the Watchdog's other
+	// Tasks skip their scheduled executions until every gate has
+	// succeeded once
+	Gate bool
+	// How long a gate may take to succeed before a Degradation is
+	// reported (zero to wait silently)
+	GateTimeout time.Duration
+	// Whether to attach an Environment to each failed Execution
+	// and Stall of the Task, including the named environment
+	// variables
+	CaptureEnvironment bool
+	EnvironmentVars    []string
+	// Whether to annotate executions with runtime/trace tasks and
+	// regions, so that they show up in go tool trace
+	Trace bool
 }
 
 // Information about each execution
@@ -23,10 +87,36 @@ type Execution struct {
 	Task *Task
 	// Time the Task was originally scheduled for
 	StartedAt time.Time
+	// Time the Task Command was actually invoked (zero if it was
+	// not, e.g. because the Task Lock could not be acquired)
+	DispatchedAt time.Time
 	// Time the Task completed
 	FinishedAt time.Time
+	// How late the scheduler was in queueing the execution after
+	// StartedAt: a measure of the scheduler's own precision
+	Skew time.Duration
+	// How long the execution was queued waiting for the previous
+	// execution of the Task to finish
+	QueueWait time.Duration
+	// How long the execution was deferred waiting for the Task
+	// Window to open
+	Deferred time.Duration
+	// How long the execution then waited for other Tasks sharing
+	// its ConcurrencyKey to finish
+	MutexWait time.Duration
 	// Error returned by the Task Command
 	Error error
+	// Why the execution's context was cancelled, if it was (only
+	// for Tasks with a CommandContext)
+	CancelReason CancelReason
+	// Host environment at the time of a failure, if the Task
+	// captures one
+	Environment *Environment
+	// Severity computed from the Error, duration and Task settings
+	Severity Severity
+	// Position of this event among all the Watchdog's executions
+	// and stalls; see Seq on Stall
+	Seq uint64
 }
 
 // Information about each stall
@@ -37,35 +127,154 @@ type Stall struct {
 	StartedAt time.Time
 	// Time the task was considered stalled
 	StalledAt time.Time
+	// Critical for critical Tasks, Warning otherwise
+	Severity Severity
+	// Host environment at the time of the stall, if the Task
+	// captures one
+	Environment *Environment
+	// Position of this event among all the Watchdog's executions
+	// and stalls. Sequence numbers are strictly increasing on each
+	// channel, and a Stall always has a lower number than the
+	// Execution it belongs to, and is sent before it.
+	Seq uint64
 }
 
 // Execution monitor
 type Watchdog struct {
+	clock clock
 	tasks []*Task
+	// Copies of the tasks as they were when passed to Watch
+	configs  []Task
+	statuses []*taskStatus
+	// Mutual exclusion between tasks, by ConcurrencyKey
+	exclusions map[string]*sync.Mutex
+	gating     gating
 
 	done chan bool
 	sync sync.WaitGroup
+	stop sync.Once
+	// Held while adding goroutines to sync after Watch, so that
+	// none are added once Stop has started waiting
+	tracking sync.Mutex
 
+	// Held while numbering and sending executions and stalls
+	sequence   sync.Mutex
+	seq        uint64
 	executions chan *Execution
 	stalls     chan *Stall
+	clockJumps chan *ClockJump
+	resumes    chan *Resume
+	deferrals  chan *Deferral
+	// Sized to hold one Degradation per gate
+	degradations chan *Degradation
+
+	diagnostics diagnostics
+
+	// Updated atomically
+	droppedClockJumps uint64
+	droppedResumes    uint64
+	droppedDeferrals  uint64
 }
 
-// Create a new, running watchdog with the given task(s).
+// Create a new, running watchdog with the given task(s). A Task may
+// only be watched by one running Watchdog at a time: Watch panics if
+// the same Task (or two Tasks with the same Name) is passed twice, or
+// the Task is already being watched by a Watchdog that has not been
+// stopped. It also panics on the other configuration errors Validate
+// reports, such as Schedules shorter than the host's timers can keep
+// to (see Snapshot.ClockResolution). The panic value is the error New
+// would return; use New to get it without panicking.
 func Watch(tasks ...*Task) *Watchdog {
+	w, err := watch(wallClock{}, tasks)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// Like Watch, but returns an error describing the first problem with
+// the tasks instead of panicking
+func New(tasks ...*Task) (*Watchdog, error) {
+	return watch(wallClock{}, tasks)
+}
+
+func watch(clock clock, tasks []*Task) (*Watchdog, error) {
+	if err := validate(clock, tasks); err != nil {
+		return nil, err
+	}
+	if err := register(tasks); err != nil {
+		return nil, err
+	}
+	configs := make([]Task, len(tasks))
+	statuses := make([]*taskStatus, len(tasks))
+	exclusions := make(map[string]*sync.Mutex)
+	gates, gateTimeouts := 0, 0
+	for i, task := range tasks {
+		configs[i] = copyTask(task)
+		if key := task.ConcurrencyKey; key != "" && exclusions[key] == nil {
+			exclusions[key] = &sync.Mutex{}
+		}
+		statuses[i] = &taskStatus{
+			schedule: make(chan tick, 1),
+			status: TaskStatus{
+				Task:     task,
+				Schedule: task.Schedule,
+				Timeout:  task.Timeout,
+				Weight:   task.Weight,
+				Critical: task.Critical,
+			},
+		}
+		if task.Gate {
+			statuses[i].gate = make(chan bool)
+			gates += 1
+			if task.GateTimeout > 0 {
+				gateTimeouts += 1
+			}
+		}
+	}
 	w := &Watchdog{
+		clock:      clock,
 		tasks:      tasks,
+		configs:    configs,
+		statuses:   statuses,
+		exclusions: exclusions,
 		done:       make(chan bool),
 		executions: make(chan *Execution, 10),
 		stalls:     make(chan *Stall, 10),
+		clockJumps: make(chan *ClockJump, 10),
+		resumes:    make(chan *Resume, 10),
+		deferrals:  make(chan *Deferral, 10),
+
+		degradations: make(chan *Degradation, gates),
 	}
-	go w.run()
-	return w
+	w.gating.pending = gates
+	w.gating.open = make(chan bool)
+	if gates == 0 {
+		close(w.gating.open)
+	}
+	// N.B.: Register the goroutines before returning, so that a
+	// Stop immediately after Watch still waits for them
+	w.sync.Add(len(tasks) + gateTimeouts)
+	if !w.simulated() {
+		w.sync.Add(1)
+	}
+	w.run()
+	return w, nil
+}
+
+// Copy of a task's settings, sharing no slices with it
+func copyTask(task *Task) Task {
+	config := *task
+	config.EnvironmentVars = append([]string(nil), task.EnvironmentVars...)
+	return config
 }
 
 // Channel of executions for a given Watchdog. Note that the channel
 // must be drained promptly while the Watchdog is running: the channel
 // has a small buffer, but failure to keep up will apply backpressure
-// to the system and delay scheduling.
+// to the system and delay scheduling. Executions of each Task are sent
+// in the order they started; use Seq to order them relative to other
+// Tasks and to Stalls.
 func (w *Watchdog) Executions() <-chan *Execution {
 	return w.executions
 }
@@ -76,85 +285,402 @@ func (w *Watchdog) Stalls() <-chan *Stall {
 	return w.stalls
 }
 
+// Channel of wall-clock jumps (NTP steps, manual changes) detected
+// while the Watchdog is running. Scheduling and stall detection use
+// the monotonic clock and are not affected by jumps, but StartedAt and
+// FinishedAt timestamps are reported in wall-clock time. Unlike the
+// channels above, this one need not be drained: jumps that do not fit
+// in its buffer are dropped.
+func (w *Watchdog) ClockJumps() <-chan *ClockJump {
+	return w.clockJumps
+}
+
+// Channel of system resumes after suspend detected while the Watchdog
+// is running. The monotonic clock does not advance while suspended,
+// so executions and stall timeouts are simply paused: no ticks are
+// missed and no stalls are reported for time spent asleep. Like
+// ClockJumps, this channel need not be drained.
+func (w *Watchdog) Resumes() <-chan *Resume {
+	return w.resumes
+}
+
 func (w *Watchdog) run() {
-	for _, task := range w.tasks {
-		go w.runTask(task)
+	for i, task := range w.tasks {
+		// N.B.: Start tickers before returning, so that schedules
+		// are anchored at the time of the call to Watch
+		ticker := w.clock.newTicker(w.configs[i].Schedule)
+		go w.runTask(task, w.configs[i], w.statuses[i], ticker)
+		if config := w.configs[i]; config.Gate && config.GateTimeout > 0 {
+			since, timeout := w.clock.now(), w.clock.newTimer(config.GateTimeout)
+			go w.watchGate(task, config, w.statuses[i].gate, since, timeout)
+		}
+	}
+	// Simulated time has no wall clock to jump or suspend
+	if !w.simulated() {
+		go w.monitorClock()
+	}
+}
+
+// A scheduled execution of a task, queued until the previous
+// execution finishes
+type tick struct {
+	scheduledAt time.Time
+	queuedAt    time.Time
+}
+
+// The execution of a task currently in flight, if any, shared between
+// the task's executor and its stall monitor
+type inflight struct {
+	sync.Mutex
+	active       bool
+	stalled      bool
+	startedAt    time.Time
+	dispatchedAt time.Time
+	// Cancels the execution's context, if it has one
+	cancel context.CancelCauseFunc
+}
+
+func (i *inflight) start(startedAt, dispatchedAt time.Time, cancel context.CancelCauseFunc) {
+	i.Lock()
+	defer i.Unlock()
+	i.active, i.stalled = true, false
+	i.startedAt, i.dispatchedAt = startedAt, dispatchedAt
+	i.cancel = cancel
+}
+
+func (i *inflight) finish() {
+	i.Lock()
+	defer i.Unlock()
+	i.active = false
+}
+
+// Mark the execution in flight as stalled and report it, passing the
+// time it was scheduled for. The report is made before the execution
+// can finish. Does nothing if the stall timer fired for an execution
+// that has since finished (the firing may be received after the next
+// execution starts), or if the stall was already reported.
+func (i *inflight) stall(stalledAt time.Time, timeout time.Duration, report func(time.Time)) {
+	i.Lock()
+	defer i.Unlock()
+	if !i.active || i.stalled || stalledAt.Sub(i.dispatchedAt) < timeout {
+		return
+	}
+	i.stalled = true
+	report(i.startedAt)
+	// Without a Timeout, every execution counts as stalled, but
+	// is left to run
+	if i.cancel != nil && timeout > 0 {
+		i.cancel(CancelTimeout)
+	}
+}
+
+// Cancel the context of the execution in flight, if it has one
+func (i *inflight) abort(reason CancelReason) {
+	i.Lock()
+	defer i.Unlock()
+	if i.active && i.cancel != nil {
+		i.cancel(reason)
 	}
-	w.sync.Add(len(w.tasks))
 }
 
-func (w *Watchdog) runTask(task *Task) {
-	ticker := time.NewTicker(task.Schedule)
-	schedule := make(chan time.Time, 1)
-	inflight := make(chan time.Time, 1)
-	stallTimer := time.NewTimer(task.Schedule + 1*time.Millisecond)
-	stallTimer.Stop()
+// Run the given task on the given ticker, using a copy of its
+// configuration taken when it was registered
+func (w *Watchdog) runTask(task *Task, config Task, status *taskStatus, ticker timer) {
+	schedule := status.schedule
+	current := &inflight{}
+	stallTimer := w.clock.newTimer(config.Schedule + 1*time.Millisecond)
+	stallTimer.stop()
 	taskDone := make(chan bool, 1)
 
 	go func() {
-		for startedAt := range schedule {
-			stallTimer.Reset(task.Timeout)
-			inflight <- startedAt
-			err := task.Command(startedAt)
-			stallTimer.Stop()
-			select {
-			case <-inflight:
-			default: // Stall must have drained it
+		locked, passed := false, false
+		failures := 0
+		send := func(e *Execution) {
+			if e.Error != nil {
+				failures += 1
+				if config.CaptureEnvironment {
+					e.Environment = captureEnvironment(e.FinishedAt, config.EnvironmentVars)
+				}
+			} else {
+				failures = 0
 			}
-			finishedAt := time.Now()
-			w.executions <- &Execution{task, startedAt, finishedAt, err}
+			e.Severity = executionSeverity(&config, e, failures)
+			w.sendExecution(e, status)
+		}
+		for next := range schedule {
+			startedAt := next.scheduledAt
+			if config.Calendar != nil && !config.Calendar.Contains(startedAt) {
+				continue
+			}
+			var deferred time.Duration
+			if config.Window != nil {
+				now := w.clock.now()
+				if !config.Window.Contains(now) {
+					if !w.awaitWindow(task, &config, startedAt) {
+						continue
+					}
+					deferred = w.clock.now().Sub(now)
+				}
+			}
+			if config.Lock != nil {
+				ctx, cancel := w.storeContext(&config)
+				held, err := config.Lock.TryLock(ctx)
+				cancel()
+				// Giving up on the lock to stop is no failure
+				if err != nil && w.stopping() {
+					continue
+				}
+				if err != nil {
+					err = fmt.Errorf("watchdog: acquiring task lock: %w", err)
+					send(&Execution{Task: task, StartedAt: startedAt,
+						FinishedAt: w.clock.now(), Error: err})
+					continue
+				}
+				if !held {
+					continue
+				}
+				locked = true
+			}
+			if config.Ledger != nil {
+				period := startedAt.Truncate(config.Schedule)
+				ctx, cancel := w.storeContext(&config)
+				claimed, err := config.Ledger.Claim(ctx, period)
+				cancel()
+				if err != nil && w.stopping() {
+					continue
+				}
+				if err != nil {
+					err = fmt.Errorf("watchdog: claiming period %v: %w", period, err)
+					send(&Execution{Task: task, StartedAt: startedAt,
+						FinishedAt: w.clock.now(), Error: err})
+					continue
+				}
+				if !claimed {
+					continue
+				}
+			}
+			var tr *executionTrace
+			if config.Trace {
+				tr = startTrace(task, startedAt)
+			}
+			var mutexWait time.Duration
+			exclusion := w.exclusions[config.ConcurrencyKey]
+			if exclusion != nil {
+				waitingAt := w.clock.now()
+				tr.region("mutex wait", exclusion.Lock)
+				mutexWait = w.clock.now().Sub(waitingAt)
+			}
+			dispatchedAt := w.clock.now()
+			ctx, cancel := context.Background(), context.CancelCauseFunc(nil)
+			if config.CommandContext != nil {
+				ctx, cancel = w.executionContext(&config, startedAt, dispatchedAt)
+			}
+			current.start(startedAt, dispatchedAt, cancel)
+			if cancel != nil && w.stopping() {
+				cancel(CancelStop)
+			}
+			status.update(func(s *TaskStatus) {
+				s.Running = true
+				s.StartedAt, s.DispatchedAt = startedAt, dispatchedAt
+			})
+			stallTimer.reset(config.Timeout)
+			var err error
+			var reason CancelReason
+			tr.region("command", func() {
+				reason, err = invoke(ctx, cancel, &config, startedAt)
+			})
+			stallTimer.stop()
+			current.finish()
+			if exclusion != nil {
+				exclusion.Unlock()
+			}
+			if config.Gate && err == nil && !passed {
+				passed = true
+				w.passGate(status.gate)
+			}
+			tr.end(err)
+			send(&Execution{
+				Task:         task,
+				StartedAt:    startedAt,
+				DispatchedAt: dispatchedAt,
+				FinishedAt:   w.clock.now(),
+				Skew:         next.queuedAt.Sub(startedAt),
+				QueueWait:    dispatchedAt.Sub(next.queuedAt) - deferred - mutexWait,
+				Deferred:     deferred,
+				MutexWait:    mutexWait,
+				Error:        err,
+				CancelReason: reason,
+			})
+		}
+		if locked {
+			config.Lock.Unlock()
 		}
 		taskDone <- true
 	}()
 	processStall := func(stalledAt time.Time) {
-		select {
-		case startedAt := <-inflight:
-			if stalledAt.Sub(startedAt) >= task.Timeout {
-				w.stalls <- &Stall{task, startedAt, stalledAt}
-			} else {
-				panic("unexpected stall before timeout was reached")
+		current.stall(stalledAt, config.Timeout, func(startedAt time.Time) {
+			status.update(func(s *TaskStatus) {
+				s.Stalled = true
+				s.Stalls += 1
+			})
+			stall := &Stall{Task: task, StartedAt: startedAt,
+				StalledAt: stalledAt, Severity: stallSeverity(&config)}
+			if config.CaptureEnvironment {
+				stall.Environment = captureEnvironment(stalledAt, config.EnvironmentVars)
 			}
-		default:
-			// Race condition with finish of
-			// execution or second (or later)
-			// stall for a given execution--ignore
-		}
+			w.sendStall(stall)
+			w.diagnoseStall(stall, config.Critical)
+		})
 	}
 monitor:
 	for {
 		select {
 		case <-w.done:
-			ticker.Stop()
+			ticker.stop()
 			close(schedule)
+			current.abort(CancelStop)
 			break monitor
-		case stalledAt := <-stallTimer.C:
+		case stalledAt := <-stallTimer.channel():
 			processStall(stalledAt)
-		case scheduledAt := <-ticker.C:
+		case scheduledAt := <-ticker.channel():
+			if !config.Gate && !w.gatesOpen() {
+				continue
+			}
 			select {
-			case schedule <- scheduledAt:
+			case schedule <- tick{scheduledAt, w.clock.now()}:
 			default:
+				status.update(func(s *TaskStatus) { s.DroppedTicks += 1 })
 			}
 		}
 	}
 cleanup:
 	for {
 		select {
-		case stalledAt := <-stallTimer.C:
+		case stalledAt := <-stallTimer.channel():
 			processStall(stalledAt)
 		case <-taskDone:
-			stallTimer.Stop()
+			stallTimer.stop()
 			break cleanup
 		}
 	}
 	w.sync.Done()
 }
 
+// Whether Stop has been called
+func (w *Watchdog) stopping() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Add a goroutine for Stop to wait for, unless the Watchdog is
+// already stopping
+func (w *Watchdog) track() bool {
+	w.tracking.Lock()
+	defer w.tracking.Unlock()
+	if w.stopping() {
+		return false
+	}
+	w.sync.Add(1)
+	return true
+}
+
+// Wait until the Task's Window contains the current time, unless the
+// Watchdog stops first, reporting a Deferral as soon as the wait
+// exceeds the Task's MaxDeferral. Should the window's Next not move
+// forward, check again after the Task's Schedule.
+func (w *Watchdog) awaitWindow(task *Task, config *Task, startedAt time.Time) bool {
+	since := w.clock.now()
+	var exceeded <-chan time.Time
+	if config.MaxDeferral > 0 {
+		timer := w.clock.newTimer(config.MaxDeferral)
+		defer timer.stop()
+		exceeded = timer.channel()
+	}
+	for {
+		now := w.clock.now()
+		if config.Window.Contains(now) {
+			return true
+		}
+		wait := config.Window.Next(now).Sub(now)
+		if wait <= 0 {
+			wait = config.Schedule
+		}
+		timer := w.clock.newTimer(wait)
+	waiting:
+		for {
+			select {
+			case <-timer.channel():
+				break waiting
+			case exceededAt := <-exceeded:
+				exceeded = nil
+				w.sendDeferral(&Deferral{Task: task, StartedAt: startedAt,
+					Since: since, ExceededAt: exceededAt, Severity: stallSeverity(config)})
+			case <-w.done:
+				timer.stop()
+				return false
+			}
+		}
+	}
+}
+
+// Number and send events under a single lock, so that sequence
+// numbers are strictly increasing on each channel. Executions of
+// watched tasks are recorded in the task's status (given, if any)
+// once numbered, before being sent: the status keeps its own copy,
+// since the consumer owns the one sent.
+func (w *Watchdog) sendExecution(e *Execution, status *taskStatus) {
+	w.sequence.Lock()
+	defer w.sequence.Unlock()
+	w.seq += 1
+	e.Seq = w.seq
+	if status != nil {
+		last := *e
+		status.update(func(s *TaskStatus) {
+			s.Running, s.Stalled = false, false
+			s.Executions += 1
+			if e.Error != nil {
+				s.Failures += 1
+			}
+			s.Last = &last
+		})
+	}
+	w.executions <- e
+}
+
+func (w *Watchdog) sendStall(s *Stall) {
+	w.sequence.Lock()
+	defer w.sequence.Unlock()
+	w.seq += 1
+	s.Seq = w.seq
+	w.stalls <- s
+}
+
 // Stop a running Watchdog. Waits for any currently-executing tasks to
-// complete, then closes the Executions and Stalls channels and
-// returns.
+// complete, then closes the Executions, Stalls, ClockJumps, Resumes,
+// Degradations and Deferrals channels and returns. Events sent before
+// the channels are closed remain buffered and can still be received.
+//
+// Stop may be called more than once, and from several goroutines at
+// once: every call returns only after the Watchdog has fully stopped.
+// The channel accessors keep returning the same (closed) channels
+// afterwards.
 func (w *Watchdog) Stop() {
-	close(w.done)
-	w.sync.Wait()
-	close(w.executions)
-	close(w.stalls)
+	w.stop.Do(func() {
+		w.tracking.Lock()
+		close(w.done)
+		w.tracking.Unlock()
+		w.sync.Wait()
+		w.diagnostics.pending.Wait()
+		unregister(w.tasks)
+		close(w.executions)
+		close(w.stalls)
+		close(w.clockJumps)
+		close(w.resumes)
+		close(w.deferrals)
+		close(w.degradations)
+	})
 }
@@ -3,6 +3,7 @@ package watchdog
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -145,6 +146,8 @@ func within(a, b time.Time, delta time.Duration) bool {
 
 func TestScheduling(t *testing.T) {
 	for i, workload := range workloads {
+		// N.B.: Tasks run concurrently, so the counts need a lock
+		var countsMu sync.Mutex
 		execCounts := make(map[*Task]int)
 		tasks := make([]*Task, len(workload.Tasks))
 		taskMap := make(map[*taskInfo]*Task)
@@ -162,14 +165,18 @@ func TestScheduling(t *testing.T) {
 			// references task itself
 			task.Command = func(ts time.Time) error {
 				execs := taskProto.Executions
+				countsMu.Lock()
 				execCount := execCounts[task]
+				countsMu.Unlock()
 				if expected := len(execs); execCount >= expected {
 					return fmt.Errorf("workload %d task %v: expected %v executions; got more at %v",
 						i, task, expected, ts)
 				}
 				exec := execs[execCount]
 				time.Sleep(exec.Duration)
+				countsMu.Lock()
 				execCounts[task] += 1
+				countsMu.Unlock()
 				return exec.Error
 			}
 
@@ -264,3 +271,361 @@ func TestScheduling(t *testing.T) {
 		<-time.After(maxFreq)
 	}
 }
+
+func TestConcurrentStop(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		var mu sync.Mutex
+		invocations := 0
+		w := Watch(&Task{
+			Schedule: 1 * time.Millisecond,
+			Command: func(time.Time) error {
+				mu.Lock()
+				invocations += 1
+				mu.Unlock()
+				return nil
+			},
+			Timeout: 1 * time.Second,
+		})
+		// Slow consumer: let the buffer fill up so that
+		// executions are blocked on delivery when Stop is called
+		received := make(chan int)
+		go func() {
+			count := 0
+			for range w.Executions() {
+				count += 1
+				time.Sleep(1 * time.Millisecond)
+			}
+			received <- count
+		}()
+		go func() {
+			for range w.Stalls() {
+			}
+		}()
+		<-time.After(time.Duration(i) * time.Millisecond)
+
+		var stoppers sync.WaitGroup
+		for j := 0; j < 10; j++ {
+			stoppers.Add(1)
+			go func() {
+				w.Stop()
+				stoppers.Done()
+			}()
+		}
+		stoppers.Wait()
+		w.Stop()
+
+		if count := <-received; count != invocations {
+			t.Errorf("iteration %d: expected all %d executions to be delivered; got %d",
+				i, invocations, count)
+		}
+		if _, ok := <-w.Executions(); ok {
+			t.Errorf("iteration %d: expected Executions to stay closed after Stop", i)
+		}
+		if _, ok := <-w.Stalls(); ok {
+			t.Errorf("iteration %d: expected Stalls to stay closed after Stop", i)
+		}
+	}
+}
+
+func TestStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+	for i := 0; i < 10; i++ {
+		var mu sync.Mutex
+		invocations := make(map[*Task]int)
+		tasks := make([]*Task, 20)
+		for j := range tasks {
+			task := &Task{
+				Schedule: time.Duration(j%5+1) * time.Millisecond,
+				Timeout:  time.Duration(j%3+1) * time.Millisecond,
+			}
+			// Durations straddle the timeout so that stalls
+			// race with completions
+			duration := time.Duration(j%4) * time.Millisecond
+			task.Command = func(time.Time) error {
+				time.Sleep(duration)
+				mu.Lock()
+				invocations[task] += 1
+				mu.Unlock()
+				return nil
+			}
+			tasks[j] = task
+		}
+		w := Watch(tasks...)
+
+		execs := make(map[*Task]int)
+		stalls := make(map[*Task]int)
+		done := make(chan bool)
+		go func() {
+			for e := range w.Executions() {
+				execs[e.Task] += 1
+				if e.FinishedAt.Before(e.StartedAt) {
+					t.Errorf("iteration %d: execution finished at %v before starting at %v",
+						i, e.FinishedAt, e.StartedAt)
+				}
+				if len(execs)%7 == 0 {
+					time.Sleep(100 * time.Microsecond)
+				}
+			}
+			done <- true
+		}()
+		go func() {
+			for s := range w.Stalls() {
+				stalls[s.Task] += 1
+				if elapsed := s.StalledAt.Sub(s.StartedAt); elapsed < s.Task.Timeout {
+					t.Errorf("iteration %d: stall reported after %v; timeout is %v",
+						i, elapsed, s.Task.Timeout)
+				}
+				time.Sleep(200 * time.Microsecond)
+			}
+			done <- true
+		}()
+
+		<-time.After(50 * time.Millisecond)
+		var stoppers sync.WaitGroup
+		for j := 0; j < 5; j++ {
+			stoppers.Add(1)
+			go func() {
+				w.Stop()
+				stoppers.Done()
+			}()
+		}
+		stoppers.Wait()
+		<-done
+		<-done
+
+		for _, task := range tasks {
+			if execs[task] != invocations[task] {
+				t.Errorf("iteration %d task %v: expected %d executions; got %d",
+					i, task, invocations[task], execs[task])
+			}
+			if stalls[task] > execs[task] {
+				t.Errorf("iteration %d task %v: expected at most one stall per execution; got %d stalls for %d executions",
+					i, task, stalls[task], execs[task])
+			}
+		}
+	}
+}
+
+func TestEventOrdering(t *testing.T) {
+	tasks := make([]*Task, 5)
+	for j := range tasks {
+		duration := time.Duration(j%3) * time.Millisecond
+		tasks[j] = &Task{
+			Schedule: 2 * time.Millisecond,
+			Command: func(time.Time) error {
+				time.Sleep(duration)
+				return nil
+			},
+			Timeout: 1 * time.Millisecond,
+		}
+	}
+	w := Watch(tasks...)
+
+	var execs []*Execution
+	var stalls []*Stall
+	done := make(chan bool)
+	go func() {
+		for e := range w.Executions() {
+			execs = append(execs, e)
+		}
+		done <- true
+	}()
+	go func() {
+		for s := range w.Stalls() {
+			stalls = append(stalls, s)
+		}
+		done <- true
+	}()
+	<-time.After(100 * time.Millisecond)
+	w.Stop()
+	<-done
+	<-done
+
+	if len(stalls) == 0 {
+		t.Fatalf("expected some stalls")
+	}
+	seen := make(map[uint64]bool)
+	type execKey struct {
+		task      *Task
+		startedAt time.Time
+	}
+	execSeqs := make(map[execKey]uint64)
+	lastStart := make(map[*Task]time.Time)
+	for i, e := range execs {
+		if i > 0 && e.Seq <= execs[i-1].Seq {
+			t.Errorf("expected execution sequence numbers to increase; got %d after %d",
+				e.Seq, execs[i-1].Seq)
+		}
+		if e.StartedAt.Before(lastStart[e.Task]) {
+			t.Errorf("task %v: expected executions in start order; got %v after %v",
+				e.Task, e.StartedAt, lastStart[e.Task])
+		}
+		lastStart[e.Task] = e.StartedAt
+		execSeqs[execKey{e.Task, e.StartedAt}] = e.Seq
+		seen[e.Seq] = true
+	}
+	for i, s := range stalls {
+		if i > 0 && s.Seq <= stalls[i-1].Seq {
+			t.Errorf("expected stall sequence numbers to increase; got %d after %d",
+				s.Seq, stalls[i-1].Seq)
+		}
+		if seen[s.Seq] {
+			t.Errorf("expected sequence number %d to be unique", s.Seq)
+		}
+		execSeq, ok := execSeqs[execKey{s.Task, s.StartedAt}]
+		if !ok {
+			t.Errorf("task %v: expected an execution for stall started at %v",
+				s.Task, s.StartedAt)
+		} else if execSeq <= s.Seq {
+			t.Errorf("task %v: expected stall %d to precede its execution; got execution %d",
+				s.Task, s.Seq, execSeq)
+		}
+	}
+}
+
+func TestTaskCopiedOnWatch(t *testing.T) {
+	var mu sync.Mutex
+	original, replaced := 0, 0
+	task := &Task{
+		Schedule: 10 * time.Millisecond,
+		Command: func(time.Time) error {
+			mu.Lock()
+			original += 1
+			mu.Unlock()
+			return nil
+		},
+		Timeout: 1 * time.Second,
+	}
+	w := Watch(task)
+	discard(w)
+	task.Schedule = 1 * time.Hour
+	task.Timeout = 1 * time.Nanosecond
+	task.Command = func(time.Time) error {
+		mu.Lock()
+		replaced += 1
+		mu.Unlock()
+		return nil
+	}
+	<-time.After(55 * time.Millisecond)
+	w.Stop()
+
+	if replaced != 0 {
+		t.Errorf("expected replaced command not to run; ran %d times", replaced)
+	}
+	if original < 3 {
+		t.Errorf("expected original command to keep running on its schedule; ran %d times",
+			original)
+	}
+}
+
+func TestDispatchTiming(t *testing.T) {
+	w := Watch(&Task{
+		Schedule: 20 * time.Millisecond,
+		Command: func(time.Time) error {
+			time.Sleep(30 * time.Millisecond)
+			return nil
+		},
+		Timeout: 1 * time.Second,
+	})
+	var execs []*Execution
+	done := make(chan bool)
+	go func() {
+		for e := range w.Executions() {
+			execs = append(execs, e)
+		}
+		done <- true
+	}()
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+	<-time.After(110 * time.Millisecond)
+	w.Stop()
+	<-done
+
+	if len(execs) < 3 {
+		t.Fatalf("expected at least 3 executions; got %d", len(execs))
+	}
+	slack := 10 * time.Millisecond
+	for i, exec := range execs {
+		if exec.Skew < 0 || exec.Skew > slack {
+			t.Errorf("execution %d: expected scheduler skew within %v; got %v", i, slack, exec.Skew)
+		}
+		if exec.QueueWait < 0 {
+			t.Errorf("execution %d: expected non-negative queue wait; got %v", i, exec.QueueWait)
+		}
+		if waited := exec.DispatchedAt.Sub(exec.StartedAt); waited < exec.Skew+exec.QueueWait {
+			t.Errorf("execution %d: expected dispatch %v after start to cover skew %v and queue wait %v",
+				i, waited, exec.Skew, exec.QueueWait)
+		}
+	}
+	if wait := execs[0].QueueWait; wait > slack {
+		t.Errorf("expected first execution not to queue; waited %v", wait)
+	}
+	// The Command overruns its Schedule, so later ticks queue up
+	// behind the execution in flight
+	if wait := execs[1].QueueWait; wait < 5*time.Millisecond {
+		t.Errorf("expected second execution to queue behind the first; waited %v", wait)
+	}
+}
+
+func TestConcurrencyKey(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	command := func(time.Time) error {
+		mu.Lock()
+		running += 1
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(15 * time.Millisecond)
+		mu.Lock()
+		running -= 1
+		mu.Unlock()
+		return nil
+	}
+	tasks := make([]*Task, 3)
+	for i := range tasks {
+		tasks[i] = &Task{
+			Schedule:       20 * time.Millisecond,
+			Command:        command,
+			Timeout:        1 * time.Second,
+			ConcurrencyKey: "legacy-system",
+		}
+	}
+	w := Watch(tasks...)
+	var execs []*Execution
+	done := make(chan bool)
+	go func() {
+		for e := range w.Executions() {
+			execs = append(execs, e)
+		}
+		done <- true
+	}()
+	go func() {
+		for range w.Stalls() {
+		}
+	}()
+	<-time.After(100 * time.Millisecond)
+	w.Stop()
+	<-done
+
+	if maxRunning != 1 {
+		t.Errorf("expected tasks sharing a key never to run together; saw %d at once", maxRunning)
+	}
+	waited := false
+	for _, exec := range execs {
+		if exec.MutexWait > 5*time.Millisecond {
+			waited = true
+		}
+		if exec.QueueWait < 0 {
+			t.Errorf("expected queue wait to exclude mutex wait; got %v", exec.QueueWait)
+		}
+	}
+	if !waited {
+		t.Errorf("expected some executions to wait for the concurrency key")
+	}
+}